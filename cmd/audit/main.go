@@ -1,6 +1,11 @@
 package main
 
 import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
 	"go-node-audit/config"
 	"go-node-audit/internal/audit"
 
@@ -16,8 +21,11 @@ func main() {
 		log.Fatalf("ParseConfig: %v", err)
 	}
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	auditService := audit.New(cfg)
-	if err := auditService.Start(); err != nil {
+	if err := auditService.Start(ctx); err != nil {
 		log.Fatalf("Audit failed: %v", err)
 	}
 }
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"go-node-audit/config"
+	"go-node-audit/internal/alert"
+	"go-node-audit/internal/explorer"
+	"go-node-audit/pkg/rpc"
+
+	golog "github.com/ipfs/go-log"
+)
+
+var log = golog.Logger("Main")
+
+func main() {
+	log.Info("Starting explorer stream audit")
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("ParseConfig: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if cfg.Explorer.VerifyRpc == "" {
+		log.Fatal("EXPLORER_VERIFY_RPC is required, records are verified against a dedicated node")
+	}
+	client := rpc.NewRPCClient(rpc.JsonRpcUrl(cfg.Explorer.VerifyRpc))
+	client.Auth = cfg.Explorer.VerifyAuth.Auth()
+	if cfg.RpcCacheSize > 0 {
+		client.Cache = rpc.NewCache(cfg.RpcCacheSize, cfg.CacheFinalityDepth)
+	}
+	telegram := alert.NewTelegram(cfg.TelegramBotToken)
+	auditor := explorer.NewStreamAuditor(cfg.Explorer, client, telegram.Async(cfg.Explorer.GroupId))
+
+	var source explorer.MessageSource
+	switch cfg.Explorer.Source {
+	case "kafka":
+		source = explorer.NewKafkaSource(cfg.Explorer.KafkaBrokers, cfg.Explorer.KafkaGroupId, auditor.Topics())
+	case "file":
+		source, err = explorer.NewFileSource(cfg.Explorer.File)
+		if err != nil {
+			log.Fatalf("Open replay file: %v", err)
+		}
+	default:
+		log.Fatalf("Unknown explorer source %q, expected kafka or file", cfg.Explorer.Source)
+	}
+	defer source.Close()
+
+	if err := auditor.Run(ctx, source); err != nil {
+		log.Fatalf("Explorer audit failed: %v", err)
+	}
+}
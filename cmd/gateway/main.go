@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go-node-audit/config"
+	"go-node-audit/internal/audit"
+	"go-node-audit/internal/gateway"
+	"go-node-audit/pkg/rpc"
+
+	golog "github.com/ipfs/go-log"
+)
+
+var log = golog.Logger("Main")
+
+const shutdownTimeout = 30 * time.Second
+
+func main() {
+	log.Info("Starting JSON-RPC gateway")
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("ParseConfig: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	backends, err := gateway.Backends(cfg, cfg.Gateway.Nodes)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// the gateway routes on the health view of its own audit, the audit job sends the alerts
+	auditor := audit.NewSilent(cfg)
+	go func() {
+		if err := auditor.Start(ctx); err != nil {
+			log.Fatalf("Audit failed: %v", err)
+		}
+	}()
+
+	balancer := gateway.NewBalancer(auditor.Health, backends, cfg.Gateway.FailureCooldown)
+	balancer.Classes = cfg.Gateway.NodeClasses
+	balancer.ForwardClasses = cfg.Gateway.ForwardClasses
+	policy := gateway.NewPolicy(cfg.Gateway)
+	balancer.Policy = policy
+	handler := rpc.NewServer(balancer)
+	handler.MaxBatchSize = cfg.Gateway.MaxBatchSize
+	handler.Timeout = cfg.Gateway.Timeout
+	handler.Policy = policy
+	if cfg.Gateway.CacheSize > 0 {
+		handler.Cache = rpc.NewCache(cfg.Gateway.CacheSize, cfg.CacheFinalityDepth)
+	}
+	var shadow *gateway.Shadow
+	if cfg.Gateway.ShadowRate > 0 {
+		shadows, err := gateway.Backends(cfg, cfg.Gateway.ShadowNodes)
+		if err != nil {
+			log.Fatal(err)
+		}
+		shadow, err = gateway.NewShadow(shadows, cfg.Gateway.ShadowMethods, cfg.Gateway.ShadowRate, cfg.Gateway.ShadowLog)
+		if err != nil {
+			log.Fatalf("Open shadow log: %v", err)
+		}
+		defer shadow.Close()
+		handler.Observe = shadow.Observe
+	}
+	// requests in flight get shutdownTimeout to finish, then their forwards are aborted
+	requests, abort := context.WithCancel(context.Background())
+	defer abort()
+	server := &http.Server{
+		Addr:        cfg.Gateway.Listen,
+		Handler:     handler,
+		BaseContext: func(net.Listener) context.Context { return requests },
+	}
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdown); err != nil {
+			log.Errorf("Shutdown: %v", err)
+			abort()
+			server.Close()
+		}
+	}()
+
+	go func() {
+		report := time.NewTicker(cfg.ReportPeriod)
+		defer report.Stop()
+		heads := time.NewTicker(cfg.PollInterval)
+		defer heads.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-heads.C:
+				if handler.Cache != nil {
+					for _, health := range auditor.Health() {
+						handler.Cache.SetHead(health.Height)
+					}
+				}
+			case <-report.C:
+				log.Info(handler.ForwardReport())
+				if handler.Cache != nil {
+					log.Infof("Gateway cache %s", handler.Cache.Stats())
+				}
+				for _, backend := range backends {
+					if backend.Client.Limiter != nil {
+						log.Infof("%s node gateway limiter %s", backend.Name, backend.Client.Limiter.Stats())
+					}
+				}
+				if shadow != nil {
+					log.Info(shadow.String())
+				}
+			}
+		}
+	}()
+
+	log.Infof("Listening on %s", cfg.Gateway.Listen)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("Gateway failed: %v", err)
+	}
+	// ListenAndServe returns as soon as the shutdown starts
+	<-stopped
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"go-node-audit/config"
+	"go-node-audit/internal/explorer"
+
+	golog "github.com/ipfs/go-log"
+)
+
+var log = golog.Logger("Main")
+
+func main() {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("ParseConfig: %v", err)
+	}
+	if cfg.Explorer.File == "" {
+		log.Fatal("EXPLORER_FILE is required, the report is built from a dump of the subscriber topics")
+	}
+	source, err := explorer.NewFileSource(cfg.Explorer.File)
+	if err != nil {
+		log.Fatalf("Open dump: %v", err)
+	}
+	defer source.Close()
+
+	tracker := explorer.NewLatencyTracker(cfg.Explorer.MaxIngestionLatency, cfg.Explorer.LatencyRetention, nil)
+	from, to, err := explorer.ReplayLatency(context.Background(), source, cfg.Explorer, tracker)
+	if err != nil {
+		log.Fatalf("Replay dump: %v", err)
+	}
+	fmt.Print(tracker.Report(from, to))
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"go-node-audit/config"
+	"go-node-audit/internal/explorer"
+	"go-node-audit/pkg/rpc"
+
+	golog "github.com/ipfs/go-log"
+)
+
+var log = golog.Logger("Main")
+
+// Audits a JSONL dump of the explorer topics (EXPLORER_FILE) for records of non-canonical
+// blocks and prints them to stdout, one JSON object per line, for cleanup.
+func main() {
+	log.Info("Starting orphaned records audit")
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("ParseConfig: %v", err)
+	}
+	if cfg.Explorer.VerifyRpc == "" {
+		log.Fatal("EXPLORER_VERIFY_RPC is required, records are verified against a dedicated node")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	source, err := explorer.NewFileSource(cfg.Explorer.File)
+	if err != nil {
+		log.Fatalf("Open dump file: %v", err)
+	}
+	defer source.Close()
+
+	store, err := explorer.LoadStore(ctx, cfg.Explorer, source)
+	if err != nil {
+		log.Fatalf("Load dump file: %v", err)
+	}
+	from, to, ok := store.Range()
+	if !ok {
+		log.Info("Dump file holds no records")
+		return
+	}
+
+	client := rpc.NewRPCClient(rpc.JsonRpcUrl(cfg.Explorer.VerifyRpc))
+	client.Auth = cfg.Explorer.VerifyAuth.Auth()
+	orphans, err := explorer.NewOrphanAuditor(store, client, cfg.Explorer.VerifyBatchSize).Audit(ctx, from, to)
+	if err != nil {
+		log.Fatalf("Orphan audit failed: %v", err)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	for _, orphan := range orphans {
+		if err := encoder.Encode(orphan); err != nil {
+			log.Fatalf("Write orphan: %v", err)
+		}
+	}
+	log.Infof("Found %d orphaned records in blocks %d-%d", len(orphans), from, to)
+}
@@ -4,6 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"os"
+	"strings"
+	"time"
+
+	"go-node-audit/pkg/rpc"
 
 	"github.com/ardanlabs/conf/v3"
 	"github.com/joho/godotenv"
@@ -11,15 +16,70 @@ import (
 
 // App config struct
 type Config struct {
-	Logger           Logger
-	MavisRpc         string `json:"mavis_rpc" conf:"default:https://api.roninchain.com/rpc,env:MAVIS_RPC"`
-	InfinityRpc      string `json:"infinity_rpc" conf:"env:INFINITY_RPC"`
-	InfinityNvRpc    string `json:"infinity_nv_rpc" conf:"env:INFINITY_NV_RPC"`
-	EternityRpc      string `json:"eternity_rpc" conf:"env:ETERNITY_RPC"`
-	CatalystRpc      string `json:"catalyst_rpc" conf:"env:CATALYST_RPC"`
-	InfinityGroupId  int    `json:"infinity_group_id" conf:"default:4282374336,env:INFINITY_GROUP_ID"`
-	RoninNodeGroupId int    `json:"ronin_node_group_id" conf:"default:947505775,env:RONIN_NODE_GROUP_ID"`
-	MaxBlockDelay    uint64 `json:"max_block_delay" conf:"default:5,env:MAX_BLOCK_DELAY"`
+	Logger        Logger
+	Explorer      Explorer
+	Gateway       Gateway
+	MavisRpc      string `json:"mavis_rpc" conf:"default:https://api.roninchain.com/rpc,env:MAVIS_RPC"`
+	InfinityRpc   string `json:"infinity_rpc" conf:"env:INFINITY_RPC"`
+	InfinityNvRpc string `json:"infinity_nv_rpc" conf:"env:INFINITY_NV_RPC"`
+	EternityRpc   string `json:"eternity_rpc" conf:"env:ETERNITY_RPC"`
+	CatalystRpc   string `json:"catalyst_rpc" conf:"env:CATALYST_RPC"`
+	MavisWs       string `json:"mavis_ws" conf:"env:MAVIS_WS"`
+	InfinityWs    string `json:"infinity_ws" conf:"env:INFINITY_WS"`
+	InfinityNvWs  string `json:"infinity_nv_ws" conf:"env:INFINITY_NV_WS"`
+	EternityWs    string `json:"eternity_ws" conf:"env:ETERNITY_WS"`
+	CatalystWs    string `json:"catalyst_ws" conf:"env:CATALYST_WS"`
+	// read from MAVIS_AUTH_USERNAME, MAVIS_AUTH_PASSWORD and so on
+	MavisAuth        EndpointAuth `json:"mavis_auth"`
+	InfinityAuth     EndpointAuth `json:"infinity_auth"`
+	InfinityNvAuth   EndpointAuth `json:"infinity_nv_auth"`
+	EternityAuth     EndpointAuth `json:"eternity_auth"`
+	CatalystAuth     EndpointAuth `json:"catalyst_auth"`
+	InfinityGroupId  int          `json:"infinity_group_id" conf:"default:4282374336,env:INFINITY_GROUP_ID"`
+	RoninNodeGroupId int          `json:"ronin_node_group_id" conf:"default:947505775,env:RONIN_NODE_GROUP_ID"`
+	MaxBlockDelay    uint64       `json:"max_block_delay" conf:"default:5,env:MAX_BLOCK_DELAY"`
+	// polling interval for nodes without a WebSocket endpoint
+	PollInterval time.Duration `json:"poll_interval" conf:"default:1s,env:POLL_INTERVAL"`
+	HeadTimeout  time.Duration `json:"head_timeout" conf:"default:1m,env:HEAD_TIMEOUT"`
+	// p95 delay after the earliest node reports a block
+	MaxPropagationDelay time.Duration `json:"max_propagation_delay" conf:"default:1s,env:MAX_PROPAGATION_DELAY"`
+	PropagationWindow   int           `json:"propagation_window" conf:"default:200,env:PROPAGATION_WINDOW"`
+	ReportPeriod        time.Duration `json:"report_period" conf:"default:10m,env:REPORT_PERIOD"`
+	ReorgWindow         int           `json:"reorg_window" conf:"default:64,env:REORG_WINDOW"`
+	MinReorgAlertDepth  int           `json:"min_reorg_alert_depth" conf:"default:2,env:MIN_REORG_ALERT_DEPTH"`
+	// ronin mainnet, 2021 is saigon testnet
+	ChainId             uint64        `json:"chain_id" conf:"default:2020,env:CHAIN_ID"`
+	CheckGenesis        bool          `json:"check_genesis" conf:"default:true,env:CHECK_GENESIS"`
+	IdentityCheckPeriod time.Duration `json:"identity_check_period" conf:"default:10m,env:IDENTITY_CHECK_PERIOD"`
+	StatusCheckPeriod   time.Duration `json:"status_check_period" conf:"default:30s,env:STATUS_CHECK_PERIOD"`
+	MaxSyncingDuration  time.Duration `json:"max_syncing_duration" conf:"default:5m,env:MAX_SYNCING_DURATION"`
+	MinPeerCount        uint64        `json:"min_peer_count" conf:"default:5,env:MIN_PEER_COUNT"`
+	// node name to group, nodes of a group must run the same client version
+	NodeGroups map[string]string `json:"node_groups" conf:"default:infinity:infinity;infinity-nv:infinity;eternity:ronin;catalyst:ronin,env:NODE_GROUPS"`
+	// group to the client version it is being upgraded to, a change to it is not alerted
+	ExpectedClientVersions map[string]string `json:"expected_client_versions" conf:"env:EXPECTED_CLIENT_VERSIONS"`
+	VersionCheckPeriod     time.Duration     `json:"version_check_period" conf:"default:5m,env:VERSION_CHECK_PERIOD"`
+	ClientVersionLog       string            `json:"client_version_log" conf:"env:CLIENT_VERSION_LOG"`
+	LatencyProbeMethods    []string          `json:"latency_probe_methods" conf:"default:eth_blockNumber;eth_getBlockByNumber;eth_getLogs,env:LATENCY_PROBE_METHODS"`
+	LatencyProbePeriod     time.Duration     `json:"latency_probe_period" conf:"default:15s,env:LATENCY_PROBE_PERIOD"`
+	LatencyProbeWindow     int               `json:"latency_probe_window" conf:"default:100,env:LATENCY_PROBE_WINDOW"`
+	LatencyProbeLogRange   uint64            `json:"latency_probe_log_range" conf:"default:10,env:LATENCY_PROBE_LOG_RANGE"`
+	MaxRpcLatency          time.Duration     `json:"max_rpc_latency" conf:"default:2s,env:MAX_RPC_LATENCY"`
+	MaxRpcLatencyRatio     float64           `json:"max_rpc_latency_ratio" conf:"default:3,env:MAX_RPC_LATENCY_RATIO"`
+	SyntheticChecksFile    string            `json:"synthetic_checks_file" conf:"env:SYNTHETIC_CHECKS_FILE"`
+	SyntheticCheckPeriod   time.Duration     `json:"synthetic_check_period" conf:"default:1m,env:SYNTHETIC_CHECK_PERIOD"`
+	HistoryProbePeriod     time.Duration     `json:"history_probe_period" conf:"default:1h,env:HISTORY_PROBE_PERIOD"`
+	HistorySamples         int               `json:"history_samples" conf:"default:5,env:HISTORY_SAMPLES"`
+	// nodes expected to serve the state of every block, the state of the others is pruned
+	ArchiveNodes []string `json:"archive_nodes" conf:"env:ARCHIVE_NODES"`
+	// bytes of final block data every RPC client caches, disabled when 0
+	RpcCacheSize       int    `json:"rpc_cache_size" conf:"default:16777216,env:RPC_CACHE_SIZE"`
+	CacheFinalityDepth uint64 `json:"cache_finality_depth" conf:"default:64,env:CACHE_FINALITY_DEPTH"`
+	// node name to requests per second every client sends it, unlimited when absent
+	RpcRateLimits map[string]float64 `json:"rpc_rate_limits" conf:"default:mavis:10,env:RPC_RATE_LIMITS"`
+	// node name to requests every client has in flight to it at once
+	RpcMaxInFlight   map[string]int `json:"rpc_max_in_flight" conf:"default:mavis:4,env:RPC_MAX_IN_FLIGHT"`
+	TelegramBotToken string         `json:"telegram_bot_token" conf:"env:TELEGRAM_BOT_TOKEN,mask"`
 }
 
 // Logger config
@@ -31,6 +91,138 @@ type Logger struct {
 	Level             string `json:"log_level" conf:"default:info,env:LOG_LEVEL"`
 }
 
+// Explorer stream audit config
+type Explorer struct {
+	Source              string        `json:"explorer_source" conf:"default:kafka,env:EXPLORER_SOURCE"`
+	File                string        `json:"explorer_file" conf:"env:EXPLORER_FILE"`
+	VerifyRpc           string        `json:"explorer_verify_rpc" conf:"env:EXPLORER_VERIFY_RPC"`
+	VerifyAuth          EndpointAuth  `json:"explorer_verify_auth"`
+	VerifyWorkers       int           `json:"explorer_verify_workers" conf:"default:4,env:EXPLORER_VERIFY_WORKERS"`
+	VerifyBatchSize     int           `json:"explorer_verify_batch_size" conf:"default:100,env:EXPLORER_VERIFY_BATCH_SIZE"`
+	KafkaBrokers        []string      `json:"kafka_brokers" conf:"default:localhost:9092,env:KAFKA_BROKERS"`
+	KafkaGroupId        string        `json:"kafka_group_id" conf:"default:node-audit,env:KAFKA_GROUP_ID"`
+	BlockTopic          string        `json:"block_topic" conf:"default:blocks,env:BLOCK_TOPIC"`
+	TransactionTopic    string        `json:"transaction_topic" conf:"default:transactions,env:TRANSACTION_TOPIC"`
+	LogTopic            string        `json:"log_topic" conf:"default:logs,env:LOG_TOPIC"`
+	InternalTxTopic     string        `json:"internal_tx_topic" conf:"default:internal_transactions,env:INTERNAL_TX_TOPIC"`
+	DirtyAccountTopic   string        `json:"dirty_account_topic" conf:"default:dirty_accounts,env:DIRTY_ACCOUNT_TOPIC"`
+	CompletionDepth     uint64        `json:"completion_depth" conf:"default:10,env:EXPLORER_COMPLETION_DEPTH"`
+	MaxIngestionLatency time.Duration `json:"max_ingestion_latency" conf:"default:30s,env:MAX_INGESTION_LATENCY"`
+	LatencyRetention    int           `json:"latency_retention" conf:"default:10000,env:LATENCY_RETENTION"`
+	LatencyReportPeriod time.Duration `json:"latency_report_period" conf:"default:10m,env:LATENCY_REPORT_PERIOD"`
+	StoreRetention      int           `json:"store_retention" conf:"default:10000,env:EXPLORER_STORE_RETENTION"`
+	OrphanAuditPeriod   time.Duration `json:"orphan_audit_period" conf:"default:10m,env:ORPHAN_AUDIT_PERIOD"`
+	GroupId             int           `json:"explorer_group_id" conf:"default:947505775,env:EXPLORER_GROUP_ID"`
+	// blocks without an issue of a kind before it recovers
+	IssueWindow uint64 `json:"issue_window" conf:"default:100,env:EXPLORER_ISSUE_WINDOW"`
+}
+
+// JSON-RPC gateway config
+type Gateway struct {
+	Listen string `json:"gateway_listen" conf:"default::8545,env:GATEWAY_LISTEN"`
+	// nodes requests are routed to, the healthiest first
+	Nodes        []string      `json:"gateway_nodes" conf:"default:infinity;infinity-nv;eternity;catalyst,env:GATEWAY_NODES"`
+	Timeout      time.Duration `json:"gateway_timeout" conf:"default:10s,env:GATEWAY_TIMEOUT"`
+	MaxBatchSize int           `json:"gateway_max_batch_size" conf:"default:100,env:GATEWAY_MAX_BATCH_SIZE"`
+	CacheSize    int           `json:"gateway_cache_size" conf:"default:67108864,env:GATEWAY_CACHE_SIZE"`
+	// node name to class, for example infinity:full;infinity-nv:archive
+	NodeClasses map[string]string `json:"gateway_node_classes" conf:"env:GATEWAY_NODE_CLASSES"`
+	// class to the class the requests its nodes answer SmartGatewayForwardNeeded to go to, for example full:archive
+	ForwardClasses map[string]string `json:"gateway_forward_classes" conf:"env:GATEWAY_FORWARD_CLASSES"`
+	// method patterns, a name or a prefix ending with *, the longest matching pattern wins
+	AllowedMethods []string `json:"gateway_allowed_methods" conf:"env:GATEWAY_ALLOWED_METHODS"`
+	DeniedMethods  []string `json:"gateway_denied_methods" conf:"default:admin_*;debug_*;miner_*;personal_*,env:GATEWAY_DENIED_METHODS"`
+	// method pattern to the class of the nodes serving it, for example debug_*:tracing
+	MethodRoutes map[string]string `json:"gateway_method_routes" conf:"env:GATEWAY_METHOD_ROUTES"`
+	// class of the nodes serving reads more than HistoryDepth blocks below the head
+	HistoryClass   string                   `json:"gateway_history_class" conf:"env:GATEWAY_HISTORY_CLASS"`
+	HistoryDepth   uint64                   `json:"gateway_history_depth" conf:"default:128,env:GATEWAY_HISTORY_DEPTH"`
+	MethodTimeouts map[string]time.Duration `json:"gateway_method_timeouts" conf:"env:GATEWAY_METHOD_TIMEOUTS"`
+	// API key to requests per second
+	ApiKeyRates   map[string]float64 `json:"gateway_api_key_rates" conf:"env:GATEWAY_API_KEY_RATES,mask"`
+	RequireApiKey bool               `json:"gateway_require_api_key" conf:"default:false,env:GATEWAY_REQUIRE_API_KEY"`
+	// requests per second shared by the clients without API key, unlimited when 0
+	AnonymousRate float64 `json:"gateway_anonymous_rate" conf:"env:GATEWAY_ANONYMOUS_RATE"`
+	// share of the pinned read-only requests compared with the shadow nodes, disabled when 0
+	ShadowRate    float64  `json:"gateway_shadow_rate" conf:"env:GATEWAY_SHADOW_RATE"`
+	ShadowNodes   []string `json:"gateway_shadow_nodes" conf:"default:infinity;eternity;catalyst,env:GATEWAY_SHADOW_NODES"`
+	ShadowMethods []string `json:"gateway_shadow_methods" conf:"default:eth_getBlockByHash;eth_getBlockByNumber;eth_getTransactionByHash;eth_getTransactionReceipt;eth_getLogs;eth_getBalance;eth_getTransactionCount;eth_getCode;eth_getStorageAt;eth_call,env:GATEWAY_SHADOW_METHODS"`
+	// JSONL file the mismatches are appended to, with both results
+	ShadowLog string `json:"gateway_shadow_log" conf:"env:GATEWAY_SHADOW_LOG"`
+	// how long a node that failed a request is only tried last
+	FailureCooldown time.Duration `json:"gateway_failure_cooldown" conf:"default:30s,env:GATEWAY_FAILURE_COOLDOWN"`
+}
+
+// Authentication of an RPC endpoint, its environment variables are prefixed with the name of
+// the field holding it. A secret written file:<path> is read from that file.
+type EndpointAuth struct {
+	Username    string `json:"username"`
+	Password    string `json:"password" conf:"mask"`
+	BearerToken string `json:"bearer_token" conf:"mask"`
+	// extra headers, for example X-Api-Key=abc;X-Team=audit
+	Headers []string `json:"headers" conf:"mask"`
+	// query parameter the API key is sent in
+	ApiKeyParam string `json:"api_key_param"`
+	ApiKey      string `json:"api_key" conf:"mask"`
+}
+
+// Auth returns the authentication the RPC clients apply, nil when none is configured.
+func (a EndpointAuth) Auth() *rpc.Auth {
+	if a.Username == "" && a.Password == "" && a.BearerToken == "" && len(a.Headers) == 0 && a.ApiKeyParam == "" {
+		return nil
+	}
+	auth := &rpc.Auth{
+		Username:    a.Username,
+		Password:    a.Password,
+		BearerToken: a.BearerToken,
+		Headers:     make(map[string]string),
+		ApiKeyParam: a.ApiKeyParam,
+		ApiKey:      a.ApiKey,
+	}
+	for _, header := range a.Headers {
+		name, value, _ := strings.Cut(header, "=")
+		auth.Headers[name] = value
+	}
+	return auth
+}
+
+// readSecrets replaces the secrets written file:<path> with the content of the file and checks
+// that every setting is complete.
+func (a *EndpointAuth) readSecrets() error {
+	if (a.ApiKeyParam == "") != (a.ApiKey == "") {
+		return errors.New("the API key and its query parameter must be set together")
+	}
+	var err error
+	for _, secret := range []*string{&a.Password, &a.BearerToken, &a.ApiKey} {
+		if *secret, err = readSecret(*secret); err != nil {
+			return err
+		}
+	}
+	for i, header := range a.Headers {
+		name, value, ok := strings.Cut(header, "=")
+		if !ok || name == "" {
+			// not quoted, the entry may be the secret
+			return fmt.Errorf("header %d must be written as Name=value", i+1)
+		}
+		if value, err = readSecret(value); err != nil {
+			return err
+		}
+		a.Headers[i] = name + "=" + value
+	}
+	return nil
+}
+
+func readSecret(value string) (string, error) {
+	if !strings.HasPrefix(value, "file:") {
+		return value, nil
+	}
+	content, err := os.ReadFile(strings.TrimPrefix(value, "file:"))
+	if err != nil {
+		return "", fmt.Errorf("cannot read secret: %w", err)
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
 // Parse config file
 func LoadConfig() (*Config, error) {
 	godotenv.Load()
@@ -43,6 +235,19 @@ func LoadConfig() (*Config, error) {
 
 		log.Fatal(err)
 	}
+	auths := map[string]*EndpointAuth{
+		"MAVIS_AUTH":           &cfg.MavisAuth,
+		"INFINITY_AUTH":        &cfg.InfinityAuth,
+		"INFINITY_NV_AUTH":     &cfg.InfinityNvAuth,
+		"ETERNITY_AUTH":        &cfg.EternityAuth,
+		"CATALYST_AUTH":        &cfg.CatalystAuth,
+		"EXPLORER_VERIFY_AUTH": &cfg.Explorer.VerifyAuth,
+	}
+	for prefix, auth := range auths {
+		if err := auth.readSecrets(); err != nil {
+			return nil, fmt.Errorf("%s: %w", prefix, err)
+		}
+	}
 
 	return cfg, nil
 }
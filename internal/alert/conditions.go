@@ -0,0 +1,61 @@
+package alert
+
+import "sync"
+
+// Conditions tracks named alert conditions and only notifies when one starts or stops,
+// so a systemic problem raises one alert instead of one per block.
+type Conditions struct {
+	mu     sync.Mutex
+	active map[string]bool
+	notify func(message string)
+}
+
+func NewConditions(notify func(message string)) *Conditions {
+	return &Conditions{
+		active: make(map[string]bool),
+		notify: notify,
+	}
+}
+
+// Breach notifies message when kind was not already active.
+func (c *Conditions) Breach(kind, message string) {
+	if c.set(kind, true) {
+		c.notify(message)
+	}
+}
+
+// Recover notifies message when kind was active.
+func (c *Conditions) Recover(kind, message string) {
+	if c.set(kind, false) {
+		c.notify(message)
+	}
+}
+
+func (c *Conditions) Active(kind string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.active[kind]
+}
+
+// Kinds returns the active conditions.
+func (c *Conditions) Kinds() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	kinds := make([]string, 0, len(c.active))
+	for kind, active := range c.active {
+		if active {
+			kinds = append(kinds, kind)
+		}
+	}
+	return kinds
+}
+
+func (c *Conditions) set(kind string, active bool) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.active[kind] == active {
+		return false
+	}
+	c.active[kind] = active
+	return true
+}
@@ -0,0 +1,82 @@
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	golog "github.com/ipfs/go-log"
+)
+
+const (
+	sendTimeout = 10 * time.Second
+	queueSize   = 100
+)
+
+var log = golog.Logger("Alert")
+
+// Telegram sends alerts to telegram groups through a bot.
+type Telegram struct {
+	token  string
+	client *http.Client
+}
+
+func NewTelegram(token string) *Telegram {
+	return &Telegram{
+		token:  token,
+		client: &http.Client{Timeout: sendTimeout},
+	}
+}
+
+// Send posts message to the telegram group groupID.
+func (t *Telegram) Send(message string, groupID int) error {
+	if t.token == "" {
+		return errors.New("telegram bot token is not configured")
+	}
+	log.Infof("Sending message %s to group %d", message, groupID)
+	endpoint := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage?chat_id=-%d&text=%s", t.token, groupID, url.QueryEscape("@here "+message))
+
+	res, err := t.client.Get(endpoint)
+	if err != nil {
+		// url.Error embeds the endpoint, which contains the bot token
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("send telegram message: %w", err)
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("read telegram response: %w", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("telegram returned status %d: %s", res.StatusCode, body)
+	}
+	log.Debugf("Telegram response: %s", body)
+	return nil
+}
+
+// Async returns a function queueing alerts to groupID, so callers never block on HTTP.
+// Alerts are dropped when the queue is full.
+func (t *Telegram) Async(groupID int) func(message string) {
+	queue := make(chan string, queueSize)
+	go func() {
+		for message := range queue {
+			if err := t.Send(message, groupID); err != nil {
+				log.Errorf("Cannot send alert to group %d: %v", groupID, err)
+			}
+		}
+	}()
+	return func(message string) {
+		select {
+		case queue <- message:
+		default:
+			log.Errorf("Alert queue of group %d is full, dropping: %s", groupID, message)
+		}
+	}
+}
@@ -1,13 +1,13 @@
 package audit
 
 import (
+	"context"
 	"fmt"
-	"go-node-audit/config"
-	"io/ioutil"
-	"net/http"
+	"sync"
 	"time"
 
-	"github.com/chenzhijie/go-web3"
+	"go-node-audit/config"
+	"go-node-audit/internal/alert"
 
 	golog "github.com/ipfs/go-log"
 )
@@ -17,73 +17,349 @@ const EmptyArrayChecksum = "0x1dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0
 var log = golog.Logger("Audit")
 
 type Audit struct {
-	cfg    *config.Config
-	failed int
+	cfg       *config.Config
+	telegram  *alert.Telegram
+	reference *Node
+	nodes     []*Node
+	// lag alerts go to the infinity group, node availability alerts to the ronin node group
+	lag          *alert.Conditions
+	health       *alert.Conditions
+	notifyLag    func(message string)
+	notifyHealth func(message string)
+
+	heights     map[string]uint64
+	lastHead    map[string]time.Time
+	propagation *Propagation
+	reorgs      map[string]*ReorgDetector
+	// nodes with a reorg check running, and the head to check next
+	reorgRunning map[string]bool
+	reorgPending map[string]nodeHead
+	wrongChain   map[string]bool
+	// set on the main loop only
+	syncingSince map[string]time.Time
+	versions     *versions
+	latency      *RpcLatency
+	history      map[string]*HistoryReport
+	// node of every alert kind about a single node
+	kindNodes map[string]string
+
+	synthetic       []SyntheticCheck
+	syntheticRounds map[string]*syntheticRound
+	// the round syntheticRounds belongs to, results of earlier rounds are not compared
+	syntheticRoundId uint64
+	onReorg          []func(reorg *Reorg)
+	// closures of off-loop work, applied on the main loop
+	results chan func()
+	// probes still running, by kind and node
+	probing map[string]bool
+
+	viewMu sync.RWMutex
+	view   map[string]NodeHealth
 }
 
 func New(cfg *config.Config) *Audit {
-	return &Audit{cfg: cfg}
+	telegram := alert.NewTelegram(cfg.TelegramBotToken)
+	reference, nodes := Nodes(cfg)
+	audit := newAudit(cfg, reference, nodes, telegram.Async(cfg.InfinityGroupId), telegram.Async(cfg.RoninNodeGroupId))
+	audit.telegram = telegram
+	return audit
 }
 
-func (audit *Audit) Start() error {
-	mavis, err := web3.NewWeb3(audit.cfg.MavisRpc)
-	if err != nil {
-		panic(err)
-	}
+// NewSilent returns an audit that logs its alerts instead of sending them, for services
+// that only need its health view next to the audit job.
+func NewSilent(cfg *config.Config) *Audit {
+	reference, nodes := Nodes(cfg)
+	notify := func(message string) { log.Info("Alert: " + message) }
+	return newAudit(cfg, reference, nodes, notify, notify)
+}
 
-	eternity, err := web3.NewWeb3(audit.cfg.EternityRpc)
-	if err != nil {
-		panic(err)
+func newAudit(cfg *config.Config, reference *Node, nodes []*Node, notifyLag, notifyHealth func(message string)) *Audit {
+	audit := &Audit{
+		cfg:          cfg,
+		reference:    reference,
+		nodes:        nodes,
+		lag:          alert.NewConditions(notifyLag),
+		health:       alert.NewConditions(notifyHealth),
+		notifyLag:    notifyLag,
+		notifyHealth: notifyHealth,
+		heights:      make(map[string]uint64),
+		lastHead:     make(map[string]time.Time),
+		propagation:  NewPropagation(cfg.PropagationWindow),
+		reorgs:       make(map[string]*ReorgDetector),
+		reorgRunning: make(map[string]bool),
+		reorgPending: make(map[string]nodeHead),
+		wrongChain:   make(map[string]bool),
+		kindNodes:    make(map[string]string),
+
+		syncingSince: make(map[string]time.Time),
+		versions:     newVersions(cfg.ClientVersionLog),
+		latency:      NewRpcLatency(cfg.LatencyProbeWindow),
+		history:      make(map[string]*HistoryReport),
+		results:      make(chan func()),
+		probing:      make(map[string]bool),
+	}
+	for _, node := range append([]*Node{reference}, nodes...) {
+		audit.reorgs[node.Name] = NewReorgDetector(node.Name, cfg.ReorgWindow, node.Client.GetBlockByHash)
 	}
+	return audit
+}
+
+// OnReorg registers fn to be called with every reorg seen by any node.
+func (audit *Audit) OnReorg(fn func(reorg *Reorg)) {
+	audit.onReorg = append(audit.onReorg, fn)
+}
 
+// Start follows the head of every node until ctx is cancelled.
+func (audit *Audit) Start(ctx context.Context) error {
 	log.Infof("Infinity group id: %d, ronin node id: %d", audit.cfg.InfinityGroupId, audit.cfg.RoninNodeGroupId)
+	if err := audit.checkIdentities(ctx); err != nil {
+		return err
+	}
+	synthetic, err := LoadSyntheticChecks(audit.cfg.SyntheticChecksFile)
+	if err != nil {
+		return fmt.Errorf("cannot load synthetic checks: %w", err)
+	}
+	audit.synthetic = synthetic
 	audit.checkErr("Ronin node monitor bot started", audit.cfg.RoninNodeGroupId)
+
+	heads := make(chan nodeHead)
+	started := time.Now()
+	for _, node := range append([]*Node{audit.reference}, audit.nodes...) {
+		audit.lastHead[node.Name] = started
+		go audit.watch(ctx, node, heads)
+	}
+
+	ticker := time.NewTicker(audit.cfg.HeadTimeout / 4)
+	defer ticker.Stop()
+	report := time.NewTicker(audit.cfg.ReportPeriod)
+	defer report.Stop()
+	identity := time.NewTicker(audit.cfg.IdentityCheckPeriod)
+	defer identity.Stop()
+	status := time.NewTicker(audit.cfg.StatusCheckPeriod)
+	defer status.Stop()
+	version := time.NewTicker(audit.cfg.VersionCheckPeriod)
+	defer version.Stop()
+	latency := time.NewTicker(audit.cfg.LatencyProbePeriod)
+	defer latency.Stop()
+	synthetics := time.NewTicker(audit.cfg.SyntheticCheckPeriod)
+	defer synthetics.Stop()
+	history := time.NewTicker(audit.cfg.HistoryProbePeriod)
+	defer history.Stop()
+	results := audit.results
+	audit.probeNodes(ctx, results, "version", audit.probeVersion)
 	for {
-		mavisBlock, err := mavis.Eth.GetBlockNumber()
-		if err != nil {
-			time.Sleep(time.Duration(500) * time.Millisecond)
-			continue
+		select {
+		case <-ctx.Done():
+			return nil
+		case head := <-heads:
+			audit.handleHead(ctx, head)
+		case now := <-ticker.C:
+			audit.checkStale(now)
+		case <-report.C:
+			log.Info(audit.propagation.String())
+			log.Info(audit.latency.String())
+			for _, node := range append([]*Node{audit.reference}, audit.nodes...) {
+				if node.Client.Cache != nil {
+					log.Infof("%s node RPC cache %s", node.Name, node.Client.Cache.Stats())
+				}
+				if node.Client.Limiter != nil {
+					log.Infof("%s node RPC limiter %s", node.Name, node.Client.Limiter.Stats())
+				}
+			}
+		case <-identity.C:
+			// the reference is alerted, monitoring goes on in case it comes back
+			if err := audit.checkIdentities(ctx); err != nil {
+				log.Error(err)
+			}
+		case <-status.C:
+			audit.probeNodes(ctx, results, "status", func(ctx context.Context, node *Node) func() {
+				status := probeStatus(ctx, node)
+				return func() { audit.handleStatus(status) }
+			})
+		case <-version.C:
+			audit.probeNodes(ctx, results, "version", audit.probeVersion)
+		case <-latency.C:
+			head := audit.heights[audit.reference.Name]
+			audit.probeNodes(ctx, results, "latency", func(ctx context.Context, node *Node) func() {
+				samples := probeLatency(ctx, node, audit.cfg.LatencyProbeMethods, head, audit.cfg.LatencyProbeLogRange)
+				return func() { audit.handleLatency(node.Name, samples) }
+			})
+		case <-synthetics.C:
+			if len(audit.synthetic) > 0 {
+				height := audit.syntheticHeight()
+				round := audit.syntheticRoundId + 1
+				nodes := audit.probeNodes(ctx, results, "synthetic", func(ctx context.Context, node *Node) func() {
+					outcome := runSyntheticChecks(ctx, node, audit.synthetic, height)
+					return func() { audit.handleSynthetic(node.Name, round, outcome) }
+				})
+				audit.startSyntheticRound(round, nodes)
+			}
+		case now := <-history.C:
+			if head := audit.heights[audit.reference.Name]; head > 0 {
+				audit.probeNodes(ctx, results, "history", func(ctx context.Context, node *Node) func() {
+					report := probeHistory(ctx, node, head, audit.cfg.HistorySamples, audit.isArchive(node.Name), now.UnixNano())
+					return func() { audit.handleHistory(report) }
+				})
+			}
+		case apply := <-results:
+			apply()
 		}
+		audit.publishHealth()
+	}
+}
 
-		eternityBlock, err := eternity.Eth.GetBlockNumber()
+func (audit *Audit) probeVersion(ctx context.Context, node *Node) func() {
+	change, err := probeVersion(ctx, node)
+	return func() {
 		if err != nil {
-			audit.checkErr("Failed to reach eternity validator node rpc many time.", audit.cfg.RoninNodeGroupId)
-			time.Sleep(time.Duration(500) * time.Millisecond)
-			continue
+			log.Warn(err)
+			return
 		}
+		audit.handleVersion(change)
+	}
+}
 
-		if eternityBlock < mavisBlock-audit.cfg.MaxBlockDelay {
-			audit.checkErr(fmt.Sprintf("Eternity non-validator node block %d, skymavis block %d, is delayed: %d blocks", eternityBlock, mavisBlock, mavisBlock-eternityBlock), audit.cfg.InfinityGroupId)
+// probeNodes runs probe for every node on the reference chain concurrently, so slow nodes do
+// not hold up head handling. The closure probe returns is applied on the main loop, unless
+// ctx is done, probes cut short by shutdown are not reported. A node whose previous probe of
+// the same kind is still running is skipped. It returns the number of nodes probed.
+func (audit *Audit) probeNodes(ctx context.Context, results chan<- func(), kind string, probe func(ctx context.Context, node *Node) func()) int {
+	probed := 0
+	for _, node := range append([]*Node{audit.reference}, audit.nodes...) {
+		key := kind + ":" + node.Name
+		if audit.wrongChain[node.Name] {
+			continue
 		}
-
-		time.Sleep(time.Duration(1000) * time.Millisecond)
+		if audit.probing[key] {
+			log.Debugf("Skipping %s probe of %s node, the previous one is still running", kind, node.Name)
+			continue
+		}
+		audit.probing[key] = true
+		probed++
+		go func(node *Node) {
+			apply := probe(ctx, node)
+			select {
+			case results <- func() {
+				delete(audit.probing, key)
+				apply()
+			}:
+			case <-ctx.Done():
+			}
+		}(node)
 	}
+	return probed
 }
 
-func (audit *Audit) checkErr(message string, groupID int) {
-	log.Infof("Sending message %s to group %d", message, groupID)
-	url := fmt.Sprintf("https://api.telegram.org/bot6599839873:AAF0-cjRn_DLtsAwkTYnI-K2vnCKQhUiVoE/sendMessage?chat_id=-%d&text=@here %s", groupID, message)
-	method := "GET"
+func (audit *Audit) handleHead(ctx context.Context, head nodeHead) {
+	number := head.head.Block.BlockNumber()
+	audit.lastHead[head.node] = head.head.Received
+	audit.health.Recover(audit.nodeKind(head.node, "stale:"+head.node), fmt.Sprintf("%s node is reporting heads again at block %d", head.node, number))
+	if audit.wrongChain[head.node] {
+		return
+	}
+	for _, node := range audit.propagation.Observe(head.node, number, head.head.Block.BlockHash(), head.head.Received) {
+		audit.checkPropagation(node)
+	}
+	audit.checkReorg(ctx, head)
+	if number <= audit.heights[head.node] {
+		return
+	}
+	audit.heights[head.node] = number
 
-	client := &http.Client{}
-	req, err := http.NewRequest(method, url, nil)
+	if head.node == audit.reference.Name {
+		for _, node := range audit.nodes {
+			audit.checkLag(node.Name)
+		}
+		return
+	}
+	audit.checkLag(head.node)
+}
 
-	if err != nil {
-		fmt.Println(err)
+// checkLag compares the height of node with the reference node.
+func (audit *Audit) checkLag(node string) {
+	reference, ok := audit.heights[audit.reference.Name]
+	height, seen := audit.heights[node]
+	if !ok || !seen {
 		return
 	}
-	res, err := client.Do(req)
-	if err != nil {
-		fmt.Println(err)
+	if reference > height+audit.cfg.MaxBlockDelay {
+		audit.lag.Breach(audit.nodeKind(node, "lag:"+node), fmt.Sprintf("%s node block %d, skymavis block %d, is delayed: %d blocks", node, height, reference, reference-height))
+		return
+	}
+	audit.lag.Recover(audit.nodeKind(node, "lag:"+node), fmt.Sprintf("%s node caught up at block %d, skymavis block %d", node, height, reference))
+}
+
+// checkReorg observes head with the reorg detector of its node off the main loop, the
+// ancestry walk fetches blocks. Heads arriving meanwhile wait, only the latest is kept, the
+// detector fetches the blocks in between.
+func (audit *Audit) checkReorg(ctx context.Context, head nodeHead) {
+	detector, ok := audit.reorgs[head.node]
+	if !ok {
+		return
+	}
+	if audit.reorgRunning[head.node] {
+		audit.reorgPending[head.node] = head
 		return
 	}
-	defer res.Body.Close()
+	audit.reorgRunning[head.node] = true
+	go func() {
+		reorg, err := detector.Observe(ctx, head.head.Block)
+		select {
+		case audit.results <- func() { audit.handleReorg(ctx, head.node, reorg, err) }:
+		case <-ctx.Done():
+		}
+	}()
+}
 
-	body, err := ioutil.ReadAll(res.Body)
+func (audit *Audit) handleReorg(ctx context.Context, node string, reorg *Reorg, err error) {
+	delete(audit.reorgRunning, node)
+	if pending, ok := audit.reorgPending[node]; ok {
+		delete(audit.reorgPending, node)
+		audit.checkReorg(ctx, pending)
+	}
 	if err != nil {
-		fmt.Println(err)
+		log.Warnf("Reorg detection on %s node: %v", node, err)
+	}
+	if reorg == nil {
 		return
 	}
+	log.Warn(reorg.String())
+	for _, fn := range audit.onReorg {
+		fn(reorg)
+	}
+	if reorg.Depth >= audit.cfg.MinReorgAlertDepth {
+		audit.notifyLag(reorg.String())
+	}
+}
+
+// checkPropagation alerts when the p95 propagation delay of node exceeds MaxPropagationDelay,
+// which catches a degrading node long before its lag reaches MaxBlockDelay.
+func (audit *Audit) checkPropagation(node string) {
+	summary := audit.propagation.Summary(node)
+	if summary.Count < minPropagationSamples {
+		return
+	}
+	if summary.P95 > audit.cfg.MaxPropagationDelay {
+		audit.lag.Breach(audit.nodeKind(node, "propagation:"+node), fmt.Sprintf("%s node p95 block propagation delay is %s, above %s", node, summary.P95, audit.cfg.MaxPropagationDelay))
+		return
+	}
+	audit.lag.Recover(audit.nodeKind(node, "propagation:"+node), fmt.Sprintf("%s node p95 block propagation delay recovered to %s", node, summary.P95))
+}
 
-	fmt.Println(string(body))
+// checkStale alerts on nodes that reported no head for HeadTimeout.
+func (audit *Audit) checkStale(now time.Time) {
+	for node, last := range audit.lastHead {
+		if now.Sub(last) > audit.cfg.HeadTimeout {
+			audit.health.Breach(audit.nodeKind(node, "stale:"+node), fmt.Sprintf("Failed to reach %s node, no new head for %s", node, now.Sub(last).Round(time.Second)))
+		}
+	}
+}
+
+func (audit *Audit) checkErr(message string, groupID int) {
+	if audit.telegram == nil {
+		return
+	}
+	if err := audit.telegram.Send(message, groupID); err != nil {
+		log.Errorf("Cannot send alert to group %d: %v", groupID, err)
+	}
 }
@@ -0,0 +1,92 @@
+package audit
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go-node-audit/config"
+	"go-node-audit/pkg/rpc"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// testAudit returns an audit of eternity against mavis, recording alerts in place of telegram.
+func testAudit(alerts *[]string) *Audit {
+	notify := func(message string) { *alerts = append(*alerts, message) }
+	cfg := &config.Config{MaxBlockDelay: 5, HeadTimeout: time.Minute, MaxPropagationDelay: time.Second, PropagationWindow: 100, ReorgWindow: 16, LatencyProbeWindow: 100}
+	return newAudit(cfg, newNode("mavis", "", ""), []*Node{newNode("eternity", "", "")}, notify, notify)
+}
+
+func head(node string, number uint64, received time.Time) nodeHead {
+	return nodeHead{node: node, head: rpc.Head{Block: &rpc.BlockResponse{Number: hexutil.Uint64(number)}, Received: received}}
+}
+
+func TestLagAlertsOnEdges(t *testing.T) {
+	var alerts []string
+	audit := testAudit(&alerts)
+	now := time.Now()
+
+	heads := []nodeHead{
+		head("eternity", 100, now),
+		head("mavis", 100, now),
+		head("mavis", 106, now),
+		head("mavis", 107, now),
+		head("mavis", 108, now),
+		head("eternity", 105, now),
+	}
+	for _, h := range heads {
+		audit.handleHead(context.Background(), h)
+	}
+
+	if len(alerts) != 2 {
+		t.Fatalf("got %d alerts, want breach and recovery: %v", len(alerts), alerts)
+	}
+	if !strings.Contains(alerts[0], "is delayed: 6 blocks") || !strings.Contains(alerts[1], "caught up") {
+		t.Fatalf("unexpected alerts: %v", alerts)
+	}
+}
+
+func TestStaleHeadAlert(t *testing.T) {
+	var alerts []string
+	audit := testAudit(&alerts)
+	start := time.Now()
+	audit.lastHead["eternity"] = start
+
+	audit.checkStale(start.Add(30 * time.Second))
+	audit.checkStale(start.Add(2 * time.Minute))
+	audit.checkStale(start.Add(3 * time.Minute))
+	audit.handleHead(context.Background(), head("eternity", 1, start.Add(3*time.Minute)))
+
+	if len(alerts) != 2 || !strings.Contains(alerts[0], "Failed to reach eternity") || !strings.Contains(alerts[1], "reporting heads again") {
+		t.Fatalf("unexpected alerts: %v", alerts)
+	}
+}
+
+func TestProbeNodesSkipsRunningProbes(t *testing.T) {
+	var alerts []string
+	audit := testAudit(&alerts)
+	release := make(chan struct{})
+	slow := func(ctx context.Context, node *Node) func() {
+		<-release
+		return func() {}
+	}
+
+	if probed := audit.probeNodes(context.Background(), audit.results, "status", slow); probed != 2 {
+		t.Fatalf("probed %d nodes, want 2", probed)
+	}
+	if probed := audit.probeNodes(context.Background(), audit.results, "status", slow); probed != 0 {
+		t.Fatalf("probed %d nodes while the previous probes run, want 0", probed)
+	}
+	if probed := audit.probeNodes(context.Background(), audit.results, "version", slow); probed != 2 {
+		t.Fatalf("probed %d nodes for another kind, want 2", probed)
+	}
+	close(release)
+	for i := 0; i < 4; i++ {
+		(<-audit.results)()
+	}
+	if probed := audit.probeNodes(context.Background(), audit.results, "status", slow); probed != 2 {
+		t.Fatalf("probed %d nodes once the probes finished, want 2", probed)
+	}
+}
@@ -0,0 +1,79 @@
+package audit
+
+import (
+	"sort"
+	"time"
+)
+
+// NodeHealth is the audit view of a node, safe to read from other goroutines through Health.
+type NodeHealth struct {
+	Node   string
+	Height uint64
+	// blocks behind the reference node
+	Lag uint64
+	// highest p95 latency over the probe methods
+	Latency  time.Duration
+	Failures int
+	// active alerts about the node
+	Alerts []string
+}
+
+// Healthy reports whether nothing is alerted about the node.
+func (h NodeHealth) Healthy() bool {
+	return len(h.Alerts) == 0
+}
+
+// Health returns the latest view of every monitored node, nodes without a head yet are missing.
+func (audit *Audit) Health() map[string]NodeHealth {
+	audit.viewMu.RLock()
+	defer audit.viewMu.RUnlock()
+	view := make(map[string]NodeHealth, len(audit.view))
+	for node, health := range audit.view {
+		view[node] = health
+	}
+	return view
+}
+
+// nodeKind records that the alert kind is about node and returns it.
+func (audit *Audit) nodeKind(node, kind string) string {
+	audit.kindNodes[kind] = node
+	return kind
+}
+
+// publishHealth updates the view returned by Health, on the main loop.
+func (audit *Audit) publishHealth() {
+	alerts := make(map[string][]string)
+	for _, kind := range append(audit.lag.Kinds(), audit.health.Kinds()...) {
+		// kinds about a group of nodes or a check have no node
+		if node, ok := audit.kindNodes[kind]; ok {
+			alerts[node] = append(alerts[node], kind)
+		}
+	}
+
+	reference := audit.heights[audit.reference.Name]
+	view := make(map[string]NodeHealth, len(audit.nodes)+1)
+	for _, node := range append([]*Node{audit.reference}, audit.nodes...) {
+		height, ok := audit.heights[node.Name]
+		if !ok {
+			continue
+		}
+		health := NodeHealth{Node: node.Name, Height: height, Failures: audit.latency.Failures(node.Name), Alerts: alerts[node.Name]}
+		if reference > height {
+			health.Lag = reference - height
+		}
+		for _, method := range audit.cfg.LatencyProbeMethods {
+			if p95 := audit.latency.Summary(node.Name, method).P95; p95 > health.Latency {
+				health.Latency = p95
+			}
+		}
+		if audit.wrongChain[node.Name] {
+			health.Alerts = append(health.Alerts, "chain:"+node.Name)
+		}
+		sort.Strings(health.Alerts)
+		view[node.Name] = health
+	}
+
+	audit.viewMu.Lock()
+	audit.view = view
+	audit.viewMu.Unlock()
+}
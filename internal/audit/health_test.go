@@ -0,0 +1,42 @@
+package audit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPublishHealth(t *testing.T) {
+	var alerts []string
+	audit := testAudit(&alerts)
+	now := time.Now()
+	audit.handleHead(context.Background(), head("mavis", 100, now))
+	audit.publishHealth()
+	if _, ok := audit.Health()["eternity"]; ok {
+		t.Fatal("eternity has health before its first head")
+	}
+
+	audit.handleHead(context.Background(), head("eternity", 99, now))
+	audit.health.Breach(audit.nodeKind("eternity", "latency:eternity:eth_getLogs"), "slow")
+	// a synthetic check named after another node
+	audit.health.Breach(audit.nodeKind("mavis", "call:eternity:mavis"), "failed")
+	audit.health.Breach("version:ronin", "nodes disagree")
+	audit.publishHealth()
+	view := audit.Health()
+	eternity := view["eternity"]
+	if eternity.Lag != 1 || eternity.Healthy() || len(eternity.Alerts) != 1 || eternity.Alerts[0] != "latency:eternity:eth_getLogs" {
+		t.Fatalf("unexpected eternity health %+v", eternity)
+	}
+	if mavis := view["mavis"]; len(mavis.Alerts) != 1 || mavis.Alerts[0] != "call:eternity:mavis" {
+		t.Fatalf("want only the check alert of mavis, group alerts are not about a node, got %+v", mavis)
+	}
+
+	audit.handleHead(context.Background(), head("mavis", 110, now))
+	audit.health.Recover("latency:eternity:eth_getLogs", "fast")
+	audit.health.Recover("call:eternity:mavis", "passes")
+	audit.publishHealth()
+	eternity = audit.Health()["eternity"]
+	if eternity.Lag != 11 || len(eternity.Alerts) != 1 || eternity.Alerts[0] != "lag:eternity" {
+		t.Fatalf("unexpected eternity health %+v", eternity)
+	}
+}
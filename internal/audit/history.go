@@ -0,0 +1,196 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"go-node-audit/pkg/rpc"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// History data kinds, a node can prune each one separately.
+const (
+	HistoryBlock   = "block"
+	HistoryReceipt = "receipt"
+	HistoryLogs    = "logs"
+	HistoryState   = "state"
+)
+
+// full nodes keep the state of the latest blocks only
+const recentStateBlocks = 128
+
+// HistoryReport is the earliest block a node serves per data kind, and the sampled
+// blocks above that boundary it could not serve.
+type HistoryReport struct {
+	Node     string
+	Head     uint64
+	Earliest map[string]uint64
+	Holes    map[string][]uint64
+	Err      error
+}
+
+func (r *HistoryReport) String() string {
+	kinds := make([]string, 0, len(r.Earliest))
+	for kind := range r.Earliest {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	parts := make([]string, len(kinds))
+	for i, kind := range kinds {
+		parts[i] = fmt.Sprintf("%s from %d", kind, r.Earliest[kind])
+	}
+	return fmt.Sprintf("%s node serves %s at head %d", r.Node, strings.Join(parts, ", "), r.Head)
+}
+
+// historyProbe checks which historical blocks a node serves.
+type historyProbe struct {
+	node   *Node
+	client *rpc.JsonRPCClient
+	kinds  []string
+	rnd    *rand.Rand
+}
+
+// available reports whether the node serves kind at block number.
+func (p *historyProbe) available(ctx context.Context, kind string, number uint64) bool {
+	client := p.client
+	switch kind {
+	case HistoryBlock:
+		_, err := client.GetBlockByNumber(ctx, number)
+		return err == nil
+	case HistoryReceipt:
+		// a block without txs has nothing to check, do not move the boundary because of it
+		_, err := p.firstReceipt(ctx, number)
+		return err == nil
+	case HistoryLogs:
+		// a pruned node answers an empty list, only a block whose receipt has logs tells
+		receipt, err := p.firstReceipt(ctx, number)
+		if err != nil {
+			return false
+		}
+		if receipt == nil || len(receipt.Logs) == 0 {
+			return true
+		}
+		block := hexutil.EncodeUint64(number)
+		params := json.RawMessage(fmt.Sprintf(`[{"fromBlock": "%s", "toBlock": "%s"}]`, block, block))
+		var logs []rpc.LogResponse
+		return client.Call(ctx, rpc.ETHGetLogs, params, &logs) == nil && len(logs) > 0
+	case HistoryState:
+		_, err := client.GetBalance(ctx, common.Address{}, number)
+		return err == nil
+	}
+	return false
+}
+
+// firstReceipt returns the receipt of the first tx of block number, nil when the block has no txs.
+func (p *historyProbe) firstReceipt(ctx context.Context, number uint64) (*rpc.ReceiptResponse, error) {
+	block, err := p.client.GetBlockByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	if len(block.Transactions) == 0 {
+		return nil, nil
+	}
+	receipt, err := p.client.GetTransactionReceipt(ctx, block.Transactions[0])
+	if err != nil {
+		return nil, err
+	}
+	if receipt == nil {
+		return nil, fmt.Errorf("no receipt of tx %s", block.Transactions[0].Hex())
+	}
+	return receipt, nil
+}
+
+// earliest binary searches the first block in [0, head] the node serves kind at.
+func (p *historyProbe) earliest(ctx context.Context, kind string, head uint64) uint64 {
+	if p.available(ctx, kind, 0) {
+		return 0
+	}
+	low, high := uint64(0), head
+	for high-low > 1 {
+		middle := low + (high-low)/2
+		if p.available(ctx, kind, middle) {
+			high = middle
+		} else {
+			low = middle
+		}
+	}
+	return high
+}
+
+func (p *historyProbe) run(ctx context.Context, head uint64, samples int) *HistoryReport {
+	report := &HistoryReport{Node: p.node.Name, Head: head, Earliest: make(map[string]uint64), Holes: make(map[string][]uint64)}
+	for _, kind := range p.kinds {
+		top := head
+		if kind == HistoryState && head > recentStateBlocks {
+			top = head - recentStateBlocks
+		}
+		earliest := p.earliest(ctx, kind, top)
+		report.Earliest[kind] = earliest
+		if top <= earliest {
+			continue
+		}
+		for i := 0; i < samples; i++ {
+			number := earliest + uint64(p.rnd.Int63n(int64(top-earliest)))
+			if !p.available(ctx, kind, number) {
+				report.Holes[kind] = append(report.Holes[kind], number)
+			}
+		}
+	}
+	// an unreachable node looks fully pruned, do not trust the report then
+	if _, err := p.client.GetBlockByNumber(ctx, head); err != nil {
+		report.Err = fmt.Errorf("%s node became unavailable during the history probe: %w", p.node.Name, err)
+	}
+	return report
+}
+
+func probeHistory(ctx context.Context, node *Node, head uint64, samples int, archive bool, seed int64) *HistoryReport {
+	kinds := []string{HistoryBlock, HistoryReceipt, HistoryLogs}
+	if archive {
+		kinds = append(kinds, HistoryState)
+	}
+	// cached blocks would hide a pruned node
+	probe := &historyProbe{node: node, client: node.Client.Uncached(), kinds: kinds, rnd: rand.New(rand.NewSource(seed))}
+	return probe.run(ctx, head, samples)
+}
+
+// handleHistory alerts when the earliest block a node serves moves up, the node was pruned,
+// and while sampled blocks above the boundary cannot be served.
+func (audit *Audit) handleHistory(report *HistoryReport) {
+	if report.Err != nil {
+		log.Warn(report.Err)
+		return
+	}
+	log.Info(report.String())
+
+	previous, ok := audit.history[report.Node]
+	audit.history[report.Node] = report
+	for kind, earliest := range report.Earliest {
+		if ok {
+			if before, known := previous.Earliest[kind]; known && earliest > before {
+				audit.notifyHealth(fmt.Sprintf("%s node no longer serves %s data before block %d, it served it from block %d, the node was pruned", report.Node, kind, earliest, before))
+			}
+		}
+
+		holeKind := audit.nodeKind(report.Node, "history:"+kind+":"+report.Node)
+		if holes := report.Holes[kind]; len(holes) > 0 {
+			audit.health.Breach(holeKind, fmt.Sprintf("%s node cannot serve %s data at blocks %v, although it serves it from block %d", report.Node, kind, holes, earliest))
+		} else {
+			audit.health.Recover(holeKind, fmt.Sprintf("%s node serves every sampled %s block again", report.Node, kind))
+		}
+	}
+}
+
+func (audit *Audit) isArchive(node string) bool {
+	for _, archive := range audit.cfg.ArchiveNodes {
+		if archive == node {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"go-node-audit/pkg/rpc"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// prunedNode serves blocks from blocks on, receipts from receipts on, logs from logs on and
+// state from state on. The transaction of block n has hash n and one log.
+func prunedNode(t *testing.T, blocks, receipts, logs, state uint64, holes map[uint64]bool) *Node {
+	pruned := &rpc.ErrorObject{Code: -32000, Message: "missing trie node"}
+	return fakeNode(t, "infinity-nv", func(method string, params json.RawMessage) (interface{}, *rpc.ErrorObject) {
+		var args []json.RawMessage
+		json.Unmarshal(params, &args)
+		switch method {
+		case rpc.ETHGetBlockByNumber:
+			var number hexutil.Uint64
+			json.Unmarshal(args[0], &number)
+			if uint64(number) < blocks || holes[uint64(number)] {
+				return nil, nil
+			}
+			return rpc.BlockResponse{Number: number, Transactions: []common.Hash{common.BigToHash(big.NewInt(int64(number)))}}, nil
+		case rpc.ETHGetTransactionReceipt:
+			var hash common.Hash
+			json.Unmarshal(args[0], &hash)
+			if hash.Big().Uint64() < receipts {
+				return nil, nil
+			}
+			return rpc.ReceiptResponse{TransactionHash: hash, Logs: []rpc.LogResponse{{TransactionHash: hash}}}, nil
+		case rpc.ETHGetLogs:
+			var filter struct{ FromBlock hexutil.Uint64 }
+			json.Unmarshal(args[0], &filter)
+			if uint64(filter.FromBlock) < logs {
+				return []rpc.LogResponse{}, nil
+			}
+			return []rpc.LogResponse{{BlockNumber: filter.FromBlock}}, nil
+		case rpc.ETHGetBalance:
+			var number hexutil.Uint64
+			json.Unmarshal(args[1], &number)
+			if uint64(number) < state {
+				return nil, pruned
+			}
+			return hexutil.Big{}, nil
+		}
+		return nil, &rpc.ErrorObject{Code: rpc.MethodNotFound, Message: "method not found"}
+	})
+}
+
+func TestProbeHistory(t *testing.T) {
+	tests := []struct {
+		name    string
+		archive bool
+		blocks  uint64
+		receipt uint64
+		logs    uint64
+		state   uint64
+		want    map[string]uint64
+	}{
+		{"archive", true, 0, 0, 0, 0, map[string]uint64{HistoryBlock: 0, HistoryReceipt: 0, HistoryLogs: 0, HistoryState: 0}},
+		{"pruned receipts", false, 0, 7000, 7000, 9800, map[string]uint64{HistoryBlock: 0, HistoryReceipt: 7000, HistoryLogs: 7000}},
+		{"pruned logs", false, 0, 0, 6000, 9800, map[string]uint64{HistoryBlock: 0, HistoryReceipt: 0, HistoryLogs: 6000}},
+		{"pruned archive", true, 1234, 1234, 1234, 5000, map[string]uint64{HistoryBlock: 1234, HistoryReceipt: 1234, HistoryLogs: 1234, HistoryState: 5000}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := prunedNode(t, tt.blocks, tt.receipt, tt.logs, tt.state, nil)
+			report := probeHistory(context.Background(), node, 10000, 3, tt.archive, 1)
+			if report.Err != nil {
+				t.Fatal(report.Err)
+			}
+			if len(report.Earliest) != len(tt.want) {
+				t.Fatalf("earliest %v, want %v", report.Earliest, tt.want)
+			}
+			for kind, earliest := range tt.want {
+				if report.Earliest[kind] != earliest {
+					t.Errorf("earliest %s block %d, want %d", kind, report.Earliest[kind], earliest)
+				}
+				if len(report.Holes[kind]) > 0 {
+					t.Errorf("unexpected %s holes %v", kind, report.Holes[kind])
+				}
+			}
+		})
+	}
+}
+
+func TestProbeHistoryHoles(t *testing.T) {
+	holes := make(map[uint64]bool)
+	for n := uint64(5000); n < 9000; n++ {
+		holes[n] = true
+	}
+	node := prunedNode(t, 0, 0, 0, 0, holes)
+	report := probeHistory(context.Background(), node, 10000, 20, false, 1)
+	if report.Earliest[HistoryBlock] != 0 {
+		t.Fatalf("earliest block %d, want 0", report.Earliest[HistoryBlock])
+	}
+	if len(report.Holes[HistoryBlock]) == 0 {
+		t.Fatal("no holes found in 20 samples of a node missing 40% of its blocks")
+	}
+	for _, number := range report.Holes[HistoryBlock] {
+		if !holes[number] {
+			t.Errorf("block %d reported missing", number)
+		}
+	}
+}
+
+func TestHandleHistory(t *testing.T) {
+	var alerts []string
+	audit := testAudit(&alerts)
+	report := func(earliest uint64, holes ...uint64) *HistoryReport {
+		return &HistoryReport{
+			Node:     "infinity-nv",
+			Head:     10000,
+			Earliest: map[string]uint64{HistoryReceipt: earliest},
+			Holes:    map[string][]uint64{HistoryReceipt: holes},
+		}
+	}
+
+	audit.handleHistory(report(0))
+	audit.handleHistory(report(0, 4242))
+	audit.handleHistory(report(0))
+	audit.handleHistory(report(8000))
+	audit.handleHistory(&HistoryReport{Node: "infinity-nv", Err: errors.New("connection refused")})
+	audit.handleHistory(report(8000))
+
+	want := []string{"cannot serve receipt data at blocks [4242]", "serves every sampled receipt block again", "no longer serves receipt data before block 8000, it served it from block 0"}
+	if len(alerts) != len(want) {
+		t.Fatalf("got alerts %v, want %v", alerts, want)
+	}
+	for i, substr := range want {
+		if !strings.Contains(alerts[i], substr) {
+			t.Errorf("alert %d %q, want %q", i, alerts[i], substr)
+		}
+	}
+}
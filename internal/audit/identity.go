@@ -0,0 +1,77 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ChainIdentity identifies the chain a node follows. Genesis is empty when not checked.
+type ChainIdentity struct {
+	ChainId uint64
+	Genesis common.Hash
+}
+
+func (c ChainIdentity) String() string {
+	if c.Genesis == (common.Hash{}) {
+		return fmt.Sprintf("chain id %d", c.ChainId)
+	}
+	return fmt.Sprintf("chain id %d genesis %s", c.ChainId, c.Genesis.Hex())
+}
+
+func (audit *Audit) identity(ctx context.Context, node *Node) (ChainIdentity, error) {
+	chainId, err := node.Client.ChainId(ctx)
+	if err != nil {
+		return ChainIdentity{}, fmt.Errorf("cannot fetch chain id of %s node: %w", node.Name, err)
+	}
+	identity := ChainIdentity{ChainId: chainId}
+	if audit.cfg.CheckGenesis {
+		// a cached genesis would hide a node moved to another chain
+		genesis, err := node.Client.Uncached().GetBlockByNumber(ctx, 0)
+		if err != nil {
+			return ChainIdentity{}, fmt.Errorf("cannot fetch genesis block of %s node: %w", node.Name, err)
+		}
+		identity.Genesis = genesis.Hash
+	}
+	return identity, nil
+}
+
+// checkIdentities compares the chain of every node with the reference node. Nodes on another
+// chain are excluded from monitoring until they are back on the reference chain. It returns
+// an error when the reference node itself is not on CHAIN_ID, nothing can be monitored then.
+func (audit *Audit) checkIdentities(ctx context.Context) error {
+	reference, err := audit.identity(ctx, audit.reference)
+	if err != nil {
+		// keep the previous verdicts, the next check retries
+		log.Warn(err)
+		return nil
+	}
+	if audit.cfg.ChainId != 0 && reference.ChainId != audit.cfg.ChainId {
+		err := fmt.Errorf("reference %s node is on %s, expected chain id %d", audit.reference.Name, reference, audit.cfg.ChainId)
+		audit.health.Breach(audit.nodeKind(audit.reference.Name, "chain:"+audit.reference.Name), "CRITICAL: "+err.Error())
+		return err
+	}
+	audit.health.Recover(audit.nodeKind(audit.reference.Name, "chain:"+audit.reference.Name), fmt.Sprintf("Reference %s node is back on %s", audit.reference.Name, reference))
+
+	for _, node := range audit.nodes {
+		identity, err := audit.identity(ctx, node)
+		if err != nil {
+			log.Warn(err)
+			continue
+		}
+		kind := audit.nodeKind(node.Name, "chain:"+node.Name)
+		if identity != reference {
+			if !audit.wrongChain[node.Name] {
+				audit.wrongChain[node.Name] = true
+				// the heights of another chain are meaningless
+				audit.lag.Recover(audit.nodeKind(node.Name, "lag:"+node.Name), fmt.Sprintf("%s node lag is no longer monitored", node.Name))
+			}
+			audit.health.Breach(kind, fmt.Sprintf("CRITICAL: %s node is on %s, reference %s node is on %s, the node is not monitored", node.Name, identity, audit.reference.Name, reference))
+			continue
+		}
+		delete(audit.wrongChain, node.Name)
+		audit.health.Recover(kind, fmt.Sprintf("%s node is back on %s", node.Name, reference))
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"go-node-audit/pkg/rpc"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func chainNode(t *testing.T, name string, chainId uint64, genesis common.Hash) *Node {
+	return fakeNode(t, name, func(method string, params json.RawMessage) (interface{}, *rpc.ErrorObject) {
+		switch method {
+		case rpc.ETHChainId:
+			return hexutil.Uint64(chainId), nil
+		case rpc.ETHGetBlockByNumber:
+			return rpc.BlockResponse{Hash: genesis}, nil
+		}
+		return nil, &rpc.ErrorObject{Code: rpc.MethodNotFound, Message: "method not found"}
+	})
+}
+
+func TestCheckIdentities(t *testing.T) {
+	mainnet, saigon := common.Hash{1}, common.Hash{2}
+	tests := []struct {
+		name       string
+		chainId    uint64
+		genesis    common.Hash
+		wrongChain bool
+	}{
+		{"same chain", 2020, mainnet, false},
+		{"saigon", 2021, saigon, true},
+		{"same chain id other genesis", 2020, saigon, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var alerts []string
+			audit := testAudit(&alerts)
+			audit.cfg.ChainId = 2020
+			audit.cfg.CheckGenesis = true
+			audit.reference = chainNode(t, "mavis", 2020, mainnet)
+			audit.nodes = []*Node{chainNode(t, "eternity", tt.chainId, tt.genesis)}
+
+			if err := audit.checkIdentities(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if audit.wrongChain["eternity"] != tt.wrongChain {
+				t.Fatalf("wrong chain %t, want %t", audit.wrongChain["eternity"], tt.wrongChain)
+			}
+			if tt.wrongChain && (len(alerts) != 1 || !strings.HasPrefix(alerts[0], "CRITICAL")) {
+				t.Fatalf("unexpected alerts: %v", alerts)
+			}
+		})
+	}
+}
+
+func TestReferenceOnWrongChain(t *testing.T) {
+	var alerts []string
+	audit := testAudit(&alerts)
+	audit.cfg.ChainId = 2020
+	audit.reference = chainNode(t, "mavis", 2021, common.Hash{})
+	if err := audit.checkIdentities(context.Background()); err == nil {
+		t.Fatal("expected the audit to refuse a reference node on saigon")
+	}
+}
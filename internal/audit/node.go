@@ -0,0 +1,95 @@
+package audit
+
+import (
+	"context"
+
+	"go-node-audit/config"
+	"go-node-audit/pkg/rpc"
+)
+
+// Node is a monitored RPC endpoint. Ws is optional, nodes without it are polled.
+// Nodes of the same Group are expected to run the same client version.
+type Node struct {
+	Name   string
+	Group  string
+	Rpc    string
+	Ws     string
+	Client *rpc.JsonRPCClient
+}
+
+// Nodes returns the reference node followed by every configured node.
+func Nodes(cfg *config.Config) (*Node, []*Node) {
+	reference := newNode("mavis", cfg.MavisRpc, cfg.MavisWs)
+	nodes := make([]*Node, 0)
+	for _, node := range []*Node{
+		newNode("infinity", cfg.InfinityRpc, cfg.InfinityWs),
+		newNode("infinity-nv", cfg.InfinityNvRpc, cfg.InfinityNvWs),
+		newNode("eternity", cfg.EternityRpc, cfg.EternityWs),
+		newNode("catalyst", cfg.CatalystRpc, cfg.CatalystWs),
+	} {
+		if node.Rpc != "" {
+			nodes = append(nodes, node)
+		}
+	}
+	auths := map[string]config.EndpointAuth{
+		"mavis":       cfg.MavisAuth,
+		"infinity":    cfg.InfinityAuth,
+		"infinity-nv": cfg.InfinityNvAuth,
+		"eternity":    cfg.EternityAuth,
+		"catalyst":    cfg.CatalystAuth,
+	}
+	for _, node := range append([]*Node{reference}, nodes...) {
+		node.Client.Auth = auths[node.Name].Auth()
+		if group, ok := cfg.NodeGroups[node.Name]; ok {
+			node.Group = group
+		}
+		if cfg.RpcCacheSize > 0 {
+			node.Client.Cache = rpc.NewCache(cfg.RpcCacheSize, cfg.CacheFinalityDepth)
+		}
+		rate, limited := cfg.RpcRateLimits[node.Name]
+		maxInFlight, bounded := cfg.RpcMaxInFlight[node.Name]
+		if limited || bounded {
+			node.Client.Limiter = rpc.NewLimiter(rate, int(rate), maxInFlight)
+		}
+	}
+	return reference, nodes
+}
+
+func newNode(name, rpcUrl, ws string) *Node {
+	return &Node{Name: name, Group: name, Rpc: rpcUrl, Ws: ws, Client: rpc.NewRPCClient(rpc.JsonRpcUrl(rpcUrl))}
+}
+
+// nodeHead is a head reported by a node.
+type nodeHead struct {
+	node string
+	head rpc.Head
+}
+
+// watch sends the heads of node to out, over WebSocket when the node has an endpoint for it.
+func (audit *Audit) watch(ctx context.Context, node *Node, out chan<- nodeHead) {
+	heads := make(chan rpc.Head)
+	if node.Ws != "" {
+		ws := rpc.NewWsClient(node.Ws)
+		ws.Auth = node.Client.Auth
+		ws.StaleTimeout = audit.cfg.HeadTimeout
+		go ws.SubscribeNewHeads(ctx, heads)
+	} else {
+		go node.Client.PollNewHeads(ctx, audit.cfg.PollInterval, heads)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case head := <-heads:
+			if node.Client.Cache != nil {
+				node.Client.Cache.SetHead(head.Block.BlockNumber())
+			}
+			select {
+			case out <- nodeHead{node: node.Name, head: head}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
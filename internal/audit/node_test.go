@@ -0,0 +1,30 @@
+package audit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-node-audit/pkg/rpc"
+)
+
+// fakeNode serves single JSON-RPC requests with answer, which returns the result
+// or an error object.
+func fakeNode(t *testing.T, name string, answer func(method string, params json.RawMessage) (interface{}, *rpc.ErrorObject)) *Node {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request rpc.ServerRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var params json.RawMessage
+		if request.Params != nil {
+			params = *request.Params
+		}
+		result, errorObject := answer(request.Method, params)
+		json.NewEncoder(w).Encode(rpc.ServerResponse[interface{}]{Version: rpc.JSONRPCVersion, ID: request.ID, Result: result, Error: errorObject})
+	}))
+	t.Cleanup(server.Close)
+	return newNode(name, server.URL, "")
+}
@@ -0,0 +1,117 @@
+package audit
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go-node-audit/internal/stats"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// a block is settled once the chain is this many blocks past it, late observers are ignored afterwards
+	settleBlocks = 5
+	// p95 of fewer samples is too noisy to alert on
+	minPropagationSamples = 20
+)
+
+// Propagation measures, per node, how long after the earliest observer each block hash reaches it.
+type Propagation struct {
+	window  int
+	pending map[common.Hash]*observations
+	delays  map[string]*stats.Window
+	highest uint64
+}
+
+type observations struct {
+	number uint64
+	seen   map[string]time.Time
+}
+
+func NewPropagation(window int) *Propagation {
+	return &Propagation{
+		window:  window,
+		pending: make(map[common.Hash]*observations),
+		delays:  make(map[string]*stats.Window),
+	}
+}
+
+// Observe records that node reported block hash at received. It returns the nodes whose
+// delay distribution changed because a block settled.
+func (p *Propagation) Observe(node string, number uint64, hash common.Hash, received time.Time) []string {
+	block, ok := p.pending[hash]
+	if !ok {
+		if p.highest > settleBlocks && number <= p.highest-settleBlocks {
+			return nil
+		}
+		block = &observations{number: number, seen: make(map[string]time.Time)}
+		p.pending[hash] = block
+	}
+	if _, ok := block.seen[node]; !ok {
+		block.seen[node] = received
+	}
+	if number > p.highest {
+		p.highest = number
+	}
+	return p.settle()
+}
+
+func (p *Propagation) settle() []string {
+	changed := make(map[string]bool)
+	for hash, block := range p.pending {
+		if block.number+settleBlocks > p.highest {
+			continue
+		}
+		delete(p.pending, hash)
+
+		var earliest time.Time
+		for _, received := range block.seen {
+			if earliest.IsZero() || received.Before(earliest) {
+				earliest = received
+			}
+		}
+		for node, received := range block.seen {
+			window, ok := p.delays[node]
+			if !ok {
+				window = stats.NewWindow(p.window)
+				p.delays[node] = window
+			}
+			window.Add(received.Sub(earliest))
+			changed[node] = true
+		}
+	}
+
+	nodes := make([]string, 0, len(changed))
+	for node := range changed {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// Summary returns the delay distribution of node over the window.
+func (p *Propagation) Summary(node string) stats.Summary {
+	window, ok := p.delays[node]
+	if !ok {
+		return stats.Summary{}
+	}
+	return window.Summary()
+}
+
+func (p *Propagation) String() string {
+	nodes := make([]string, 0, len(p.delays))
+	for node := range p.delays {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	var b strings.Builder
+	b.WriteString("Block propagation delay\n")
+	for _, node := range nodes {
+		fmt.Fprintf(&b, "  %-12s %s\n", node, p.Summary(node))
+	}
+	return b.String()
+}
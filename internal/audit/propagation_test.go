@@ -0,0 +1,45 @@
+package audit
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestPropagationDelayFromEarliestObserver(t *testing.T) {
+	propagation := NewPropagation(100)
+	start := time.Unix(1000, 0)
+	for number := uint64(1); number <= 10; number++ {
+		hash := common.BigToHash(new(big.Int).SetUint64(number))
+		received := start.Add(time.Duration(number) * 3 * time.Second)
+		// eternity reports after mavis, although its head arrives first on the channel
+		propagation.Observe("eternity", number, hash, received.Add(400*time.Millisecond))
+		propagation.Observe("mavis", number, hash, received)
+	}
+
+	tests := []struct {
+		node  string
+		count int
+		p95   time.Duration
+	}{
+		{"mavis", 5, 0},
+		{"eternity", 5, 400 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		summary := propagation.Summary(tt.node)
+		if summary.Count != tt.count || summary.P95 != tt.p95 {
+			t.Errorf("%s: %s, want count=%d p95=%s", tt.node, summary, tt.count, tt.p95)
+		}
+	}
+}
+
+func TestPropagationIgnoresSettledBlocks(t *testing.T) {
+	propagation := NewPropagation(100)
+	now := time.Now()
+	propagation.Observe("mavis", 100, common.Hash{1}, now)
+	if changed := propagation.Observe("eternity", 90, common.Hash{2}, now); len(changed) != 0 || len(propagation.pending) != 1 {
+		t.Fatalf("settled block tracked again: changed %v, pending %d", changed, len(propagation.pending))
+	}
+}
@@ -0,0 +1,168 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-node-audit/pkg/rpc"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Reorg is a chain reorganization seen by a node. OldBranch holds the replaced blocks and
+// NewBranch the blocks replacing them, both from the common ancestor up.
+type Reorg struct {
+	Node       string
+	Ancestor   uint64
+	Depth      int
+	OldBranch  []common.Hash
+	NewBranch  []common.Hash
+	DroppedTxs []common.Hash
+	Time       time.Time
+}
+
+func (r *Reorg) String() string {
+	return fmt.Sprintf("%s node reorg of depth %d after block %d, old branch %s, new branch %s, %d txs not in the new branch: %s",
+		r.Node, r.Depth, r.Ancestor, hashList(r.OldBranch), hashList(r.NewBranch), len(r.DroppedTxs), hashList(r.DroppedTxs))
+}
+
+func hashList(hashes []common.Hash) string {
+	hexes := make([]string, len(hashes))
+	for i, hash := range hashes {
+		hexes[i] = hash.Hex()
+	}
+	return "[" + strings.Join(hexes, " ") + "]"
+}
+
+// ReorgDetector keeps the latest size headers of one node and checks the ancestry of each new head.
+type ReorgDetector struct {
+	node   string
+	size   int
+	fetch  func(ctx context.Context, hash common.Hash) (*rpc.BlockResponse, error)
+	window map[uint64]*rpc.BlockResponse
+	lowest uint64
+}
+
+// NewReorgDetector fetches missing ancestors and the transactions of both branches with fetch.
+func NewReorgDetector(node string, size int, fetch func(ctx context.Context, hash common.Hash) (*rpc.BlockResponse, error)) *ReorgDetector {
+	return &ReorgDetector{node: node, size: size, fetch: fetch, window: make(map[uint64]*rpc.BlockResponse)}
+}
+
+// Observe adds head to the window and returns the reorg it caused, if any.
+func (d *ReorgDetector) Observe(ctx context.Context, head *rpc.BlockResponse) (*Reorg, error) {
+	number := head.BlockNumber()
+	if known, ok := d.window[number]; ok && known.Hash == head.Hash {
+		return nil, nil
+	}
+	if len(d.window) == 0 {
+		d.insert(head)
+		return nil, nil
+	}
+
+	// walk the ancestry of head back into the window
+	branch := []*rpc.BlockResponse{head}
+	current := head
+	var ancestor *rpc.BlockResponse
+	for len(branch) <= d.size {
+		parentNumber := current.BlockNumber() - 1
+		if parent, ok := d.window[parentNumber]; ok && parent.Hash == current.ParentHash {
+			ancestor = parent
+			break
+		}
+		if parentNumber < d.lowest {
+			break
+		}
+		parent, err := d.fetch(ctx, current.ParentHash)
+		if err != nil {
+			d.reset(head)
+			return nil, fmt.Errorf("cannot fetch parent %s of block %d: %w", current.ParentHash.Hex(), current.BlockNumber(), err)
+		}
+		branch = append([]*rpc.BlockResponse{parent}, branch...)
+		current = parent
+	}
+	if ancestor == nil {
+		d.reset(head)
+		return nil, fmt.Errorf("%s node head %d does not connect to the last %d blocks", d.node, number, d.size)
+	}
+
+	old := make([]*rpc.BlockResponse, 0)
+	for n := ancestor.BlockNumber() + 1; ; n++ {
+		block, ok := d.window[n]
+		if !ok {
+			break
+		}
+		old = append(old, block)
+		delete(d.window, n)
+	}
+	for _, block := range branch {
+		d.insert(block)
+	}
+	if len(old) == 0 {
+		return nil, nil
+	}
+
+	reorg := &Reorg{
+		Node:      d.node,
+		Ancestor:  ancestor.BlockNumber(),
+		Depth:     len(old),
+		OldBranch: make([]common.Hash, len(old)),
+		NewBranch: make([]common.Hash, len(branch)),
+		Time:      time.Now(),
+	}
+	for i, block := range old {
+		reorg.OldBranch[i] = block.Hash
+	}
+	for i, block := range branch {
+		reorg.NewBranch[i] = block.Hash
+	}
+	dropped, err := d.droppedTxs(ctx, old, branch)
+	reorg.DroppedTxs = dropped
+	return reorg, err
+}
+
+// droppedTxs returns the txs of the old branch that are not in the new branch.
+// Heads from a subscription carry no transactions, so both branches are fetched again.
+func (d *ReorgDetector) droppedTxs(ctx context.Context, old, branch []*rpc.BlockResponse) ([]common.Hash, error) {
+	included := make(map[common.Hash]bool)
+	for _, block := range branch {
+		full, err := d.fetch(ctx, block.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("cannot fetch new branch block %s: %w", block.Hash.Hex(), err)
+		}
+		for _, tx := range full.Transactions {
+			included[tx] = true
+		}
+	}
+	dropped := make([]common.Hash, 0)
+	for _, block := range old {
+		full, err := d.fetch(ctx, block.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("cannot fetch old branch block %s: %w", block.Hash.Hex(), err)
+		}
+		for _, tx := range full.Transactions {
+			if !included[tx] {
+				dropped = append(dropped, tx)
+			}
+		}
+	}
+	return dropped, nil
+}
+
+func (d *ReorgDetector) insert(block *rpc.BlockResponse) {
+	number := block.BlockNumber()
+	d.window[number] = block
+	if len(d.window) == 1 || number < d.lowest {
+		d.lowest = number
+	}
+	for len(d.window) > d.size {
+		delete(d.window, d.lowest)
+		d.lowest++
+	}
+}
+
+func (d *ReorgDetector) reset(head *rpc.BlockResponse) {
+	d.window = make(map[uint64]*rpc.BlockResponse)
+	d.insert(head)
+}
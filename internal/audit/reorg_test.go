@@ -0,0 +1,159 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"go-node-audit/pkg/rpc"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// testChain stores every block of every branch by hash, like a node keeping side chains.
+type testChain map[common.Hash]*rpc.BlockResponse
+
+func (c testChain) fetch(ctx context.Context, hash common.Hash) (*rpc.BlockResponse, error) {
+	block, ok := c[hash]
+	if !ok {
+		return nil, fmt.Errorf("block %s: %w", hash.Hex(), rpc.ErrNotFound)
+	}
+	return block, nil
+}
+
+// extend adds count blocks on top of parent on branch, each with one tx of its own.
+func (c testChain) extend(parent *rpc.BlockResponse, branch string, count int) []*rpc.BlockResponse {
+	blocks := make([]*rpc.BlockResponse, count)
+	for i := range blocks {
+		number := parent.BlockNumber() + 1
+		id := fmt.Sprintf("%s-%d", branch, number)
+		block := &rpc.BlockResponse{
+			Number:       hexutil.Uint64(number),
+			Hash:         crypto.Keccak256Hash([]byte(id)),
+			ParentHash:   parent.Hash,
+			Transactions: []common.Hash{crypto.Keccak256Hash([]byte("tx-" + id))},
+		}
+		c[block.Hash] = block
+		blocks[i] = block
+		parent = block
+	}
+	return blocks
+}
+
+// header drops the transactions, as a newHeads notification does.
+func header(block *rpc.BlockResponse) *rpc.BlockResponse {
+	head := *block
+	head.Transactions = nil
+	return &head
+}
+
+func TestReorgDetector(t *testing.T) {
+	tests := []struct {
+		name      string
+		oldBlocks int
+		newBlocks int
+		depth     int
+	}{
+		{"no reorg", 0, 3, 0},
+		{"sibling head", 1, 1, 1},
+		{"longer branch", 2, 3, 2},
+		{"shorter branch", 3, 2, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := testChain{}
+			genesis := &rpc.BlockResponse{Hash: common.Hash{1}}
+			chain[genesis.Hash] = genesis
+			base := chain.extend(genesis, "base", 5)
+			old := chain.extend(base[4], "old", tt.oldBlocks)
+			replacement := chain.extend(base[4], "new", tt.newBlocks)
+
+			detector := NewReorgDetector("mavis", 16, chain.fetch)
+			for _, block := range append(base, old...) {
+				if reorg, err := detector.Observe(context.Background(), header(block)); reorg != nil || err != nil {
+					t.Fatalf("unexpected reorg %v, err %v", reorg, err)
+				}
+			}
+			// only the new head arrives, its ancestors are fetched
+			reorg, err := detector.Observe(context.Background(), header(replacement[len(replacement)-1]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.depth == 0 {
+				if reorg != nil {
+					t.Fatalf("unexpected reorg %s", reorg)
+				}
+				return
+			}
+			if reorg == nil || reorg.Depth != tt.depth || reorg.Ancestor != 5 {
+				t.Fatalf("reorg %v, want depth %d after block 5", reorg, tt.depth)
+			}
+			if len(reorg.NewBranch) != tt.newBlocks || reorg.NewBranch[0] != replacement[0].Hash {
+				t.Fatalf("new branch %s", hashList(reorg.NewBranch))
+			}
+			if len(reorg.DroppedTxs) != tt.oldBlocks || reorg.DroppedTxs[0] != old[0].Transactions[0] {
+				t.Fatalf("dropped txs %s", hashList(reorg.DroppedTxs))
+			}
+		})
+	}
+}
+
+func TestReorgDetectorDisconnectedHead(t *testing.T) {
+	chain := testChain{}
+	genesis := &rpc.BlockResponse{Hash: common.Hash{1}}
+	blocks := chain.extend(genesis, "base", 10)
+
+	detector := NewReorgDetector("mavis", 4, chain.fetch)
+	detector.Observe(context.Background(), blocks[0])
+	// the window of 4 blocks cannot reach back to block 1
+	if _, err := detector.Observe(context.Background(), blocks[9]); err == nil {
+		t.Fatal("expected an error for a head that does not connect to the window")
+	}
+	if reorg, err := detector.Observe(context.Background(), blocks[9]); reorg != nil || err != nil {
+		t.Fatalf("detector not reset to the new head: %v, %v", reorg, err)
+	}
+}
+
+func TestReorgCheckOffMainLoop(t *testing.T) {
+	var alerts []string
+	audit := testAudit(&alerts)
+	chain := testChain{}
+	genesis := &rpc.BlockResponse{Hash: common.Hash{1}}
+	base := chain.extend(genesis, "base", 3)
+	old := chain.extend(base[2], "old", 1)
+	replacement := chain.extend(base[2], "new", 2)
+
+	release := make(chan struct{})
+	audit.reorgs["eternity"] = NewReorgDetector("eternity", 16, func(ctx context.Context, hash common.Hash) (*rpc.BlockResponse, error) {
+		<-release
+		return chain.fetch(ctx, hash)
+	})
+	var reorgs []*Reorg
+	audit.OnReorg(func(reorg *Reorg) { reorgs = append(reorgs, reorg) })
+	observe := func(block *rpc.BlockResponse) {
+		audit.handleHead(context.Background(), nodeHead{node: "eternity", head: rpc.Head{Block: header(block)}})
+	}
+
+	for _, block := range append(base, old...) {
+		observe(block)
+		(<-audit.results)()
+	}
+	// both heads are handled while the detector waits on its fetches
+	observe(replacement[0])
+	observe(replacement[1])
+	if len(audit.reorgPending) != 1 {
+		t.Fatalf("pending heads %v, want the latest one", audit.reorgPending)
+	}
+	close(release)
+	(<-audit.results)()
+	(<-audit.results)()
+
+	if len(reorgs) != 1 || reorgs[0].Depth != 1 || reorgs[0].Ancestor != 3 {
+		t.Fatalf("reorgs %v, want one of depth 1 after block 3", reorgs)
+	}
+	if audit.reorgRunning["eternity"] || len(audit.reorgPending) != 0 {
+		t.Fatal("reorg check still marked running")
+	}
+}
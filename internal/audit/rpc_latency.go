@@ -0,0 +1,184 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go-node-audit/internal/stats"
+	"go-node-audit/pkg/rpc"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+const (
+	minLatencySamples = 10
+	// a node is not called slower than its peers below this p95, a few ms apart is noise
+	minSlowLatency = 100 * time.Millisecond
+)
+
+// LatencySample is the duration of one probe call.
+type LatencySample struct {
+	Method   string
+	Duration time.Duration
+	Err      error
+}
+
+// RpcLatency keeps the latest probe latencies per node and method.
+type RpcLatency struct {
+	window   int
+	samples  map[string]map[string]*stats.Window
+	failures map[string]int
+}
+
+func NewRpcLatency(window int) *RpcLatency {
+	return &RpcLatency{window: window, samples: make(map[string]map[string]*stats.Window), failures: make(map[string]int)}
+}
+
+// Add records the samples of one probe round of node. Failed calls count as consecutive failures.
+func (l *RpcLatency) Add(node string, samples []LatencySample) {
+	methods, ok := l.samples[node]
+	if !ok {
+		methods = make(map[string]*stats.Window)
+		l.samples[node] = methods
+	}
+	for _, sample := range samples {
+		if sample.Err != nil {
+			l.failures[node]++
+			continue
+		}
+		l.failures[node] = 0
+		window, ok := methods[sample.Method]
+		if !ok {
+			window = stats.NewWindow(l.window)
+			methods[sample.Method] = window
+		}
+		window.Add(sample.Duration)
+	}
+}
+
+func (l *RpcLatency) Summary(node, method string) stats.Summary {
+	window, ok := l.samples[node][method]
+	if !ok {
+		return stats.Summary{}
+	}
+	return window.Summary()
+}
+
+// Failures returns the number of consecutive failed probe calls of node.
+func (l *RpcLatency) Failures(node string) int {
+	return l.failures[node]
+}
+
+// peerP95 returns the median p95 of method over every node but node.
+func (l *RpcLatency) peerP95(node, method string) time.Duration {
+	p95s := make([]time.Duration, 0)
+	for peer := range l.samples {
+		if peer == node {
+			continue
+		}
+		if summary := l.Summary(peer, method); summary.Count >= minLatencySamples {
+			p95s = append(p95s, summary.P95)
+		}
+	}
+	if len(p95s) == 0 {
+		return 0
+	}
+	sort.Slice(p95s, func(i, j int) bool { return p95s[i] < p95s[j] })
+	return stats.Percentile(p95s, 50)
+}
+
+func (l *RpcLatency) String() string {
+	nodes := make([]string, 0, len(l.samples))
+	for node := range l.samples {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	var b strings.Builder
+	b.WriteString("RPC latency\n")
+	for _, node := range nodes {
+		methods := make([]string, 0, len(l.samples[node]))
+		for method := range l.samples[node] {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+		for _, method := range methods {
+			fmt.Fprintf(&b, "  %-12s %-22s %s\n", node, method, l.Summary(node, method))
+		}
+	}
+	return b.String()
+}
+
+// probeLatency calls every probe method on node. Block based methods run at head,
+// the latest reference height, eth_getLogs over the logRange blocks up to it.
+func probeLatency(ctx context.Context, node *Node, methods []string, head, logRange uint64) []LatencySample {
+	samples := make([]LatencySample, 0, len(methods))
+	for _, method := range methods {
+		params, err := probeParams(method, head, logRange)
+		if err != nil {
+			log.Errorf("Cannot probe %s: %v", method, err)
+			continue
+		}
+		start := time.Now()
+		err = node.Client.Call(ctx, method, params, nil)
+		samples = append(samples, LatencySample{Method: method, Duration: time.Since(start), Err: err})
+		if err != nil {
+			log.Debugf("Latency probe %s on %s node failed: %v", method, node.Name, err)
+		}
+	}
+	return samples
+}
+
+func probeParams(method string, head, logRange uint64) (json.RawMessage, error) {
+	block := "latest"
+	from := "latest"
+	if head > 0 {
+		block = hexutil.EncodeUint64(head)
+		if head > logRange {
+			from = hexutil.EncodeUint64(head - logRange)
+		} else {
+			from = hexutil.EncodeUint64(0)
+		}
+	}
+	switch method {
+	case rpc.ETHBlockNumber, rpc.ETHChainId, rpc.ETHSyncing, rpc.NetPeerCount, rpc.Web3ClientVersion:
+		return json.RawMessage(`[]`), nil
+	case rpc.ETHGetBlockByNumber:
+		return json.RawMessage(fmt.Sprintf(`["%s", false]`, block)), nil
+	case rpc.ETHGetLogs:
+		return json.RawMessage(fmt.Sprintf(`[{"fromBlock": "%s", "toBlock": "%s"}]`, from, block)), nil
+	}
+	return nil, fmt.Errorf("no probe params for method %s", method)
+}
+
+// handleLatency records a probe round and alerts, per method, when the p95 of node exceeds
+// MaxRpcLatency or is MaxRpcLatencyRatio times the median p95 of its peers.
+func (audit *Audit) handleLatency(node string, samples []LatencySample) {
+	audit.latency.Add(node, samples)
+	for _, sample := range samples {
+		method := sample.Method
+		summary := audit.latency.Summary(node, method)
+		if summary.Count < minLatencySamples {
+			continue
+		}
+
+		kind := audit.nodeKind(node, "latency:"+node+":"+method)
+		if summary.P95 > audit.cfg.MaxRpcLatency {
+			audit.health.Breach(kind, fmt.Sprintf("%s node %s p95 latency is %s, above %s", node, method, summary.P95, audit.cfg.MaxRpcLatency))
+		} else {
+			audit.health.Recover(kind, fmt.Sprintf("%s node %s p95 latency recovered to %s", node, method, summary.P95))
+		}
+
+		kind = audit.nodeKind(node, "slow:"+node+":"+method)
+		peers := audit.latency.peerP95(node, method)
+		if peers > 0 && summary.P95 > minSlowLatency && float64(summary.P95) > audit.cfg.MaxRpcLatencyRatio*float64(peers) {
+			audit.health.Breach(kind, fmt.Sprintf("%s node %s p95 latency is %s, %.1fx the %s of its peers", node, method, summary.P95, float64(summary.P95)/float64(peers), peers))
+		} else {
+			audit.health.Recover(kind, fmt.Sprintf("%s node %s p95 latency is in line with its peers again", node, method))
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go-node-audit/pkg/rpc"
+)
+
+func samples(method string, duration time.Duration) []LatencySample {
+	return []LatencySample{{Method: method, Duration: duration}}
+}
+
+func TestLatencyAlerts(t *testing.T) {
+	var alerts []string
+	audit := testAudit(&alerts)
+	audit.cfg.MaxRpcLatency = 2 * time.Second
+	audit.cfg.MaxRpcLatencyRatio = 3
+
+	for i := 0; i < minLatencySamples; i++ {
+		audit.handleLatency("mavis", samples(rpc.ETHGetLogs, 200*time.Millisecond))
+		audit.handleLatency("catalyst", samples(rpc.ETHGetLogs, 250*time.Millisecond))
+		audit.handleLatency("eternity", samples(rpc.ETHGetLogs, time.Second))
+	}
+	if len(alerts) != 1 || !strings.Contains(alerts[0], "eternity node eth_getLogs p95 latency is 1s, 5.0x") {
+		t.Fatalf("want one slower than peers alert, got %v", alerts)
+	}
+
+	alerts = nil
+	for i := 0; i < minLatencySamples; i++ {
+		audit.handleLatency("eternity", samples(rpc.ETHGetLogs, 3*time.Second))
+	}
+	if len(alerts) != 1 || !strings.Contains(alerts[0], "above 2s") {
+		t.Fatalf("want one threshold alert, got %v", alerts)
+	}
+}
+
+func TestRpcLatencyFailures(t *testing.T) {
+	latency := NewRpcLatency(10)
+	failed := []LatencySample{{Method: rpc.ETHBlockNumber, Err: errors.New("timeout")}}
+	latency.Add("eternity", failed)
+	latency.Add("eternity", failed)
+	if got := latency.Failures("eternity"); got != 2 {
+		t.Fatalf("failures %d, want 2", got)
+	}
+	latency.Add("eternity", samples(rpc.ETHBlockNumber, time.Millisecond))
+	if got := latency.Failures("eternity"); got != 0 {
+		t.Fatalf("failures %d after a success, want 0", got)
+	}
+}
+
+func TestProbeLatency(t *testing.T) {
+	var methods []string
+	node := fakeNode(t, "eternity", func(method string, params json.RawMessage) (interface{}, *rpc.ErrorObject) {
+		methods = append(methods, method+string(params))
+		return "0x1", nil
+	})
+	got := probeLatency(context.Background(), node, []string{rpc.ETHBlockNumber, rpc.ETHGetLogs}, 100, 10)
+	if len(got) != 2 || got[0].Err != nil || got[1].Err != nil {
+		t.Fatalf("unexpected samples %v", got)
+	}
+	if want := `eth_getLogs[{"fromBlock":"0x5a","toBlock":"0x64"}]`; methods[1] != want {
+		t.Fatalf("sent %s, want %s", methods[1], want)
+	}
+}
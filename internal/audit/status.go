@@ -0,0 +1,85 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go-node-audit/pkg/rpc"
+)
+
+// NodeStatus is the sync and network status reported by a node. A field whose call
+// failed, for example because the net namespace is disabled, is left unknown.
+type NodeStatus struct {
+	Node      string
+	Time      time.Time
+	Syncing   *rpc.SyncStatus
+	PeerCount *uint64
+	Listening *bool
+	Err       error
+}
+
+func probeStatus(ctx context.Context, node *Node) NodeStatus {
+	status := NodeStatus{Node: node.Name, Time: time.Now()}
+	syncing, err := node.Client.Syncing(ctx)
+	if err != nil {
+		// the node cannot answer at all, stale head alerts cover it
+		status.Err = fmt.Errorf("cannot fetch sync status of %s node: %w", node.Name, err)
+		return status
+	}
+	status.Syncing = syncing
+	if peers, err := node.Client.PeerCount(ctx); err != nil {
+		log.Debugf("Cannot fetch peer count of %s node: %v", node.Name, err)
+	} else {
+		status.PeerCount = &peers
+	}
+	if listening, err := node.Client.Listening(ctx); err != nil {
+		log.Debugf("Cannot fetch listening status of %s node: %v", node.Name, err)
+	} else {
+		status.Listening = &listening
+	}
+	return status
+}
+
+// handleStatus alerts when a node has been syncing for more than MaxSyncingDuration,
+// has fewer than MinPeerCount peers or stopped listening for peers.
+func (audit *Audit) handleStatus(status NodeStatus) {
+	if status.Err != nil {
+		log.Warn(status.Err)
+		return
+	}
+	node := status.Node
+
+	if status.Syncing == nil {
+		if _, ok := audit.syncingSince[node]; ok {
+			delete(audit.syncingSince, node)
+			audit.health.Recover(audit.nodeKind(node, "syncing:"+node), fmt.Sprintf("%s node finished syncing", node))
+		}
+	} else {
+		since, ok := audit.syncingSince[node]
+		if !ok {
+			since = status.Time
+			audit.syncingSince[node] = since
+		}
+		if status.Time.Sub(since) > audit.cfg.MaxSyncingDuration {
+			audit.health.Breach(audit.nodeKind(node, "syncing:"+node), fmt.Sprintf("%s node is syncing for %s, at block %d of %d",
+				node, status.Time.Sub(since).Round(time.Second), uint64(status.Syncing.CurrentBlock), uint64(status.Syncing.HighestBlock)))
+		}
+	}
+
+	if status.PeerCount != nil {
+		if *status.PeerCount < audit.cfg.MinPeerCount {
+			audit.health.Breach(audit.nodeKind(node, "peers:"+node), fmt.Sprintf("%s node has %d peers, below %d", node, *status.PeerCount, audit.cfg.MinPeerCount))
+		} else {
+			audit.health.Recover(audit.nodeKind(node, "peers:"+node), fmt.Sprintf("%s node has %d peers again", node, *status.PeerCount))
+		}
+	}
+
+	if status.Listening != nil {
+		if !*status.Listening {
+			audit.health.Breach(audit.nodeKind(node, "listening:"+node), fmt.Sprintf("%s node is not listening for peers", node))
+		} else {
+			audit.health.Recover(audit.nodeKind(node, "listening:"+node), fmt.Sprintf("%s node is listening for peers again", node))
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"go-node-audit/pkg/rpc"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func TestProbeStatus(t *testing.T) {
+	node := fakeNode(t, "eternity", func(method string, params json.RawMessage) (interface{}, *rpc.ErrorObject) {
+		switch method {
+		case rpc.ETHSyncing:
+			return rpc.SyncStatus{CurrentBlock: 10, HighestBlock: 20}, nil
+		case rpc.NetPeerCount:
+			return hexutil.Uint64(3), nil
+		}
+		return nil, &rpc.ErrorObject{Code: rpc.MethodNotFound, Message: "the method net_listening does not exist/is not available"}
+	})
+
+	status := probeStatus(context.Background(), node)
+	if status.Err != nil || status.Syncing == nil || uint64(status.Syncing.HighestBlock) != 20 {
+		t.Fatalf("unexpected sync status %+v", status)
+	}
+	if status.PeerCount == nil || *status.PeerCount != 3 {
+		t.Fatalf("peer count %v, want 3", status.PeerCount)
+	}
+	if status.Listening != nil {
+		t.Fatal("listening known although net_listening is disabled")
+	}
+}
+
+func TestHandleStatus(t *testing.T) {
+	var alerts []string
+	audit := testAudit(&alerts)
+	audit.cfg.MaxSyncingDuration = time.Minute
+	audit.cfg.MinPeerCount = 5
+	start := time.Now()
+	peers := func(n uint64) *uint64 { return &n }
+	syncing := &rpc.SyncStatus{CurrentBlock: 10, HighestBlock: 20}
+
+	statuses := []NodeStatus{
+		{Node: "eternity", Time: start, Syncing: syncing, PeerCount: peers(10)},
+		{Node: "eternity", Time: start.Add(30 * time.Second), Syncing: syncing, PeerCount: peers(10)},
+		{Node: "eternity", Time: start.Add(90 * time.Second), Syncing: syncing, PeerCount: peers(2)},
+		{Node: "eternity", Time: start.Add(120 * time.Second), Syncing: syncing, PeerCount: peers(1)},
+		{Node: "eternity", Time: start.Add(150 * time.Second), PeerCount: peers(8)},
+	}
+	for _, status := range statuses {
+		audit.handleStatus(status)
+	}
+
+	want := []string{"is syncing for 1m30s", "has 2 peers", "finished syncing", "has 8 peers again"}
+	if len(alerts) != len(want) {
+		t.Fatalf("got alerts %v, want %v", alerts, want)
+	}
+	for i, substr := range want {
+		if !strings.Contains(alerts[i], substr) {
+			t.Errorf("alert %d %q, want %q", i, alerts[i], substr)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+)
+
+// blocks behind the lowest monitored head a "latest" check runs at, so every node has the block
+const syntheticConfirmations = 2
+
+// SyntheticCheck is an eth_call whose result is known, declared in SYNTHETIC_CHECKS_FILE:
+//
+//	[{"name": "usdc-total-supply", "to": "0x...", "data": "0x18160ddd", "expect": {"min": "1000000"}}]
+//
+// Block is "latest" by default, which runs every node at the same recent height,
+// or a block number.
+type SyntheticCheck struct {
+	Name   string         `json:"name"`
+	To     common.Address `json:"to"`
+	Data   hexutil.Bytes  `json:"data"`
+	Block  string         `json:"block"`
+	Expect Predicate      `json:"expect"`
+}
+
+// Predicate is the expected result of a check. Equals compares the whole return data. Min, Max
+// and NotZero apply to the 32-byte return word Word, 0 by default. Word 1 of a dynamic array
+// is its length.
+type Predicate struct {
+	Equals  *hexutil.Bytes        `json:"equals,omitempty"`
+	Word    int                   `json:"word,omitempty"`
+	Min     *math.HexOrDecimal256 `json:"min,omitempty"`
+	Max     *math.HexOrDecimal256 `json:"max,omitempty"`
+	NotZero bool                  `json:"notZero,omitempty"`
+}
+
+func LoadSyntheticChecks(path string) ([]SyntheticCheck, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var checks []SyntheticCheck
+	if err := json.Unmarshal(data, &checks); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for i, check := range checks {
+		if check.Name == "" {
+			return nil, fmt.Errorf("%s: check %d has no name", path, i)
+		}
+		if check.Block == "" {
+			checks[i].Block = "latest"
+		}
+	}
+	return checks, nil
+}
+
+// Check returns why result does not satisfy the predicate.
+func (p Predicate) Check(result []byte) error {
+	if p.Equals != nil && !bytes.Equal(result, *p.Equals) {
+		return fmt.Errorf("returned %s, expected %s", hexutil.Encode(result), p.Equals)
+	}
+	if p.Min == nil && p.Max == nil && !p.NotZero {
+		return nil
+	}
+	start := p.Word * 32
+	if len(result) < start+32 {
+		return fmt.Errorf("returned %d bytes, no word %d", len(result), p.Word)
+	}
+	value := new(big.Int).SetBytes(result[start : start+32])
+	if p.NotZero && value.Sign() == 0 {
+		return fmt.Errorf("word %d is zero", p.Word)
+	}
+	if p.Min != nil && value.Cmp((*big.Int)(p.Min)) < 0 {
+		return fmt.Errorf("word %d is %s, below %s", p.Word, value, (*big.Int)(p.Min))
+	}
+	if p.Max != nil && value.Cmp((*big.Int)(p.Max)) > 0 {
+		return fmt.Errorf("word %d is %s, above %s", p.Word, value, (*big.Int)(p.Max))
+	}
+	return nil
+}
+
+// SyntheticResult is the outcome of one check on one node.
+type SyntheticResult struct {
+	Check  string
+	Block  string
+	Result []byte
+	Err    error
+}
+
+// syntheticRound collects the results of one check over every node of a round.
+type syntheticRound struct {
+	block    string
+	expected int
+	answered int
+	values   map[string][]string
+}
+
+func runSyntheticChecks(ctx context.Context, node *Node, checks []SyntheticCheck, latest uint64) []SyntheticResult {
+	results := make([]SyntheticResult, len(checks))
+	for i, check := range checks {
+		block := check.Block
+		if block == "latest" && latest > 0 {
+			block = hexutil.EncodeUint64(latest)
+		}
+		result, err := node.Client.EthCall(ctx, check.To, check.Data, block)
+		results[i] = SyntheticResult{Check: check.Name, Block: block, Result: result, Err: err}
+	}
+	return results
+}
+
+// syntheticHeight is the height every monitored node should have, the lowest head minus a margin.
+func (audit *Audit) syntheticHeight() uint64 {
+	lowest := uint64(0)
+	for _, node := range append([]*Node{audit.reference}, audit.nodes...) {
+		height, ok := audit.heights[node.Name]
+		if !ok || audit.wrongChain[node.Name] {
+			continue
+		}
+		if lowest == 0 || height < lowest {
+			lowest = height
+		}
+	}
+	if lowest <= syntheticConfirmations {
+		return 0
+	}
+	return lowest - syntheticConfirmations
+}
+
+// startSyntheticRound expects a result of every check from nodes nodes in round id.
+func (audit *Audit) startSyntheticRound(id uint64, nodes int) {
+	audit.syntheticRoundId = id
+	audit.syntheticRounds = make(map[string]*syntheticRound, len(audit.synthetic))
+	for _, check := range audit.synthetic {
+		audit.syntheticRounds[check.Name] = &syntheticRound{expected: nodes, values: make(map[string][]string)}
+	}
+}
+
+// handleSynthetic alerts on failed and unexpected results of node. Once every node answered
+// a check in the current round, it alerts when nodes returned different results at the same
+// block. Late results of an earlier round are only alerted for node.
+func (audit *Audit) handleSynthetic(node string, round uint64, results []SyntheticResult) {
+	checks := make(map[string]SyntheticCheck, len(audit.synthetic))
+	for _, check := range audit.synthetic {
+		checks[check.Name] = check
+	}
+
+	for _, result := range results {
+		check := checks[result.Check]
+		kind := audit.nodeKind(node, "call:"+check.Name+":"+node)
+		if result.Err != nil {
+			audit.health.Breach(kind, fmt.Sprintf("Synthetic check %s failed on %s node at block %s: %v", check.Name, node, result.Block, result.Err))
+		} else if err := check.Expect.Check(result.Result); err != nil {
+			audit.health.Breach(kind, fmt.Sprintf("Synthetic check %s on %s node at block %s: %v", check.Name, node, result.Block, err))
+		} else {
+			audit.health.Recover(kind, fmt.Sprintf("Synthetic check %s passes on %s node again", check.Name, node))
+		}
+
+		if round != audit.syntheticRoundId {
+			log.Debugf("Not comparing synthetic check %s of %s node from round %d, round %d is running", check.Name, node, round, audit.syntheticRoundId)
+			continue
+		}
+		current, ok := audit.syntheticRounds[check.Name]
+		if !ok {
+			continue
+		}
+		current.answered++
+		current.block = result.Block
+		if result.Err == nil {
+			value := hexutil.Encode(result.Result)
+			current.values[value] = append(current.values[value], node)
+		}
+		if current.answered < current.expected {
+			continue
+		}
+		delete(audit.syntheticRounds, check.Name)
+		audit.compareSynthetic(check.Name, current)
+	}
+}
+
+func (audit *Audit) compareSynthetic(check string, round *syntheticRound) {
+	kind := "call-diverge:" + check
+	if len(round.values) <= 1 {
+		audit.health.Recover(kind, fmt.Sprintf("Nodes agree on synthetic check %s again", check))
+		return
+	}
+	parts := make([]string, 0, len(round.values))
+	for value, nodes := range round.values {
+		sort.Strings(nodes)
+		parts = append(parts, fmt.Sprintf("%s returned %s", strings.Join(nodes, ","), value))
+	}
+	sort.Strings(parts)
+	audit.health.Breach(kind, fmt.Sprintf("Nodes disagree on synthetic check %s at block %s: %s", check, round.block, strings.Join(parts, "; ")))
+}
@@ -0,0 +1,132 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go-node-audit/pkg/rpc"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func word(n int64) []byte {
+	return common.BigToHash(big.NewInt(n)).Bytes()
+}
+
+func TestPredicate(t *testing.T) {
+	var predicates []Predicate
+	if err := json.Unmarshal([]byte(`[
+		{"equals": "0x01"},
+		{"min": "100"},
+		{"max": "0x64"},
+		{"notZero": true, "word": 1}
+	]`), &predicates); err != nil {
+		t.Fatal(err)
+	}
+	// dynamic array of 3 elements, offset then length
+	array := append(word(32), word(3)...)
+
+	tests := []struct {
+		predicate Predicate
+		result    []byte
+		ok        bool
+	}{
+		{predicates[0], []byte{1}, true},
+		{predicates[0], []byte{2}, false},
+		{predicates[1], word(100), true},
+		{predicates[1], word(99), false},
+		{predicates[2], word(101), false},
+		{predicates[2], nil, false},
+		{predicates[3], array, true},
+		{predicates[3], append(word(32), word(0)...), false},
+	}
+	for i, tt := range tests {
+		if err := tt.predicate.Check(tt.result); (err == nil) != tt.ok {
+			t.Errorf("case %d: err %v, want ok %t", i, err, tt.ok)
+		}
+	}
+}
+
+func TestLoadSyntheticChecks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checks.json")
+	os.WriteFile(path, []byte(`[{"name": "paused", "to": "0x0000000000000000000000000000000000000001", "data": "0x5c975abb", "expect": {"max": "0"}}]`), 0o644)
+	checks, err := LoadSyntheticChecks(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checks) != 1 || checks[0].Block != "latest" || hexutil.Encode(checks[0].Data) != "0x5c975abb" {
+		t.Fatalf("unexpected checks %+v", checks)
+	}
+}
+
+func TestRunSyntheticChecks(t *testing.T) {
+	var sent string
+	node := fakeNode(t, "eternity", func(method string, params json.RawMessage) (interface{}, *rpc.ErrorObject) {
+		sent = string(params)
+		return hexutil.Bytes(word(5)), nil
+	})
+	checks := []SyntheticCheck{{Name: "supply", To: common.Address{1}, Data: hexutil.Bytes{0x18, 0x16, 0x0d, 0xdd}, Block: "latest"}}
+	results := runSyntheticChecks(context.Background(), node, checks, 100)
+	if results[0].Err != nil || results[0].Block != "0x64" || !strings.Contains(sent, `"0x64"`) {
+		t.Fatalf("results %+v, sent %s", results, sent)
+	}
+}
+
+func TestSyntheticDivergence(t *testing.T) {
+	var alerts []string
+	audit := testAudit(&alerts)
+	audit.synthetic = []SyntheticCheck{{Name: "supply", Expect: Predicate{NotZero: true}}}
+	result := func(n int64) []SyntheticResult {
+		return []SyntheticResult{{Check: "supply", Block: "0x64", Result: word(n)}}
+	}
+
+	audit.startSyntheticRound(1, 2)
+	audit.handleSynthetic("mavis", 1, result(5))
+	audit.handleSynthetic("eternity", 1, result(6))
+	if len(alerts) != 1 || !strings.Contains(alerts[0], "Nodes disagree on synthetic check supply") {
+		t.Fatalf("want a divergence alert, got %v", alerts)
+	}
+
+	alerts = nil
+	audit.startSyntheticRound(2, 2)
+	audit.handleSynthetic("mavis", 2, result(0))
+	audit.handleSynthetic("eternity", 2, result(0))
+	want := []string{"on mavis node at block 0x64: word 0 is zero", "on eternity node", "agree on synthetic check supply again"}
+	if len(alerts) != len(want) {
+		t.Fatalf("alerts %v, want %v", alerts, want)
+	}
+	for i, substr := range want {
+		if !strings.Contains(alerts[i], substr) {
+			t.Errorf("alert %q, want %q", alerts[i], substr)
+		}
+	}
+}
+
+func TestSyntheticDropsLateResults(t *testing.T) {
+	var alerts []string
+	audit := testAudit(&alerts)
+	audit.synthetic = []SyntheticCheck{{Name: "supply"}}
+	result := func(n int64) []SyntheticResult {
+		return []SyntheticResult{{Check: "supply", Block: "0x64", Result: word(n)}}
+	}
+
+	audit.startSyntheticRound(1, 2)
+	audit.handleSynthetic("mavis", 1, result(5))
+	// eternity answers round 1 after round 2 started
+	audit.startSyntheticRound(2, 2)
+	audit.handleSynthetic("eternity", 1, result(6))
+	audit.handleSynthetic("mavis", 2, result(5))
+	if len(alerts) != 0 {
+		t.Fatalf("late result compared: %v", alerts)
+	}
+	audit.handleSynthetic("eternity", 2, result(7))
+	if len(alerts) != 1 || !strings.Contains(alerts[0], "eternity returned "+hexutil.Encode(word(7))) {
+		t.Fatalf("alerts %v, want the divergence of round 2", alerts)
+	}
+}
@@ -0,0 +1,126 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const maxVersionHistory = 100
+
+// VersionChange records the client version a node reported from Time on.
+type VersionChange struct {
+	Node    string    `json:"node"`
+	Group   string    `json:"group"`
+	Version string    `json:"version"`
+	Time    time.Time `json:"time"`
+}
+
+// versions tracks the client version history of every node.
+type versions struct {
+	history map[string][]VersionChange
+	groups  map[string]string
+	log     string
+}
+
+func newVersions(logPath string) *versions {
+	return &versions{history: make(map[string][]VersionChange), groups: make(map[string]string), log: logPath}
+}
+
+// Current returns the latest version of node, empty when never fetched.
+func (v *versions) Current(node string) string {
+	history := v.history[node]
+	if len(history) == 0 {
+		return ""
+	}
+	return history[len(history)-1].Version
+}
+
+func (v *versions) History(node string) []VersionChange {
+	return v.history[node]
+}
+
+// record adds change when the version differs from the current one and returns the previous version.
+func (v *versions) record(change VersionChange) (string, bool) {
+	v.groups[change.Node] = change.Group
+	previous := v.Current(change.Node)
+	if previous == change.Version {
+		return previous, false
+	}
+	history := append(v.history[change.Node], change)
+	if len(history) > maxVersionHistory {
+		history = history[len(history)-maxVersionHistory:]
+	}
+	v.history[change.Node] = history
+
+	if v.log != "" {
+		if err := appendJSONLine(v.log, change); err != nil {
+			log.Errorf("Cannot write client version history: %v", err)
+		}
+	}
+	return previous, true
+}
+
+// groupVersions returns the nodes of group by current version.
+func (v *versions) groupVersions(group string) map[string][]string {
+	byVersion := make(map[string][]string)
+	for node, nodeGroup := range v.groups {
+		if nodeGroup == group {
+			version := v.Current(node)
+			byVersion[version] = append(byVersion[version], node)
+		}
+	}
+	for _, nodes := range byVersion {
+		sort.Strings(nodes)
+	}
+	return byVersion
+}
+
+func appendJSONLine(path string, value interface{}) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return json.NewEncoder(file).Encode(value)
+}
+
+func probeVersion(ctx context.Context, node *Node) (VersionChange, error) {
+	version, err := node.Client.ClientVersion(ctx)
+	if err != nil {
+		return VersionChange{}, fmt.Errorf("cannot fetch client version of %s node: %w", node.Name, err)
+	}
+	return VersionChange{Node: node.Name, Group: node.Group, Version: version, Time: time.Now()}, nil
+}
+
+// handleVersion records the version of a node. It alerts when the version changes to anything
+// but the expected version of its group, and while the nodes of the group disagree.
+func (audit *Audit) handleVersion(change VersionChange) {
+	previous, changed := audit.versions.record(change)
+	if !changed {
+		return
+	}
+	log.Infof("%s node runs %s", change.Node, change.Version)
+
+	expected, planned := audit.cfg.ExpectedClientVersions[change.Group]
+	if previous != "" && !(planned && expected == change.Version) {
+		audit.notifyHealth(fmt.Sprintf("%s node client version changed from %s to %s", change.Node, previous, change.Version))
+	}
+
+	byVersion := audit.versions.groupVersions(change.Group)
+	kind := "version:" + change.Group
+	if len(byVersion) <= 1 {
+		audit.health.Recover(kind, fmt.Sprintf("%s nodes run the same client version %s again", change.Group, change.Version))
+		return
+	}
+	parts := make([]string, 0, len(byVersion))
+	for version, nodes := range byVersion {
+		parts = append(parts, fmt.Sprintf("%s on %s", version, strings.Join(nodes, ",")))
+	}
+	sort.Strings(parts)
+	audit.health.Breach(kind, fmt.Sprintf("%s nodes run different client versions: %s", change.Group, strings.Join(parts, "; ")))
+}
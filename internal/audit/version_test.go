@@ -0,0 +1,45 @@
+package audit
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandleVersion(t *testing.T) {
+	var alerts []string
+	audit := testAudit(&alerts)
+	audit.cfg.ExpectedClientVersions = map[string]string{"ronin": "ronin/v2.8.0"}
+	now := time.Now()
+	change := func(node, version string) VersionChange {
+		return VersionChange{Node: node, Group: "ronin", Version: version, Time: now}
+	}
+
+	steps := []struct {
+		change VersionChange
+		alerts []string
+	}{
+		{change("eternity", "ronin/v2.7.0"), nil},
+		{change("catalyst", "ronin/v2.7.0"), nil},
+		{change("eternity", "ronin/v2.7.0"), nil},
+		// planned upgrade, only the drift is alerted
+		{change("eternity", "ronin/v2.8.0"), []string{"run different client versions: ronin/v2.7.0 on catalyst; ronin/v2.8.0 on eternity"}},
+		{change("catalyst", "ronin/v2.8.0"), []string{"run the same client version ronin/v2.8.0 again"}},
+		{change("catalyst", "ronin/v2.6.1"), []string{"changed from ronin/v2.8.0 to ronin/v2.6.1", "run different client versions"}},
+	}
+	for i, step := range steps {
+		alerts = nil
+		audit.handleVersion(step.change)
+		if len(alerts) != len(step.alerts) {
+			t.Fatalf("step %d: alerts %v, want %v", i, alerts, step.alerts)
+		}
+		for j, substr := range step.alerts {
+			if !strings.Contains(alerts[j], substr) {
+				t.Errorf("step %d: alert %q, want %q", i, alerts[j], substr)
+			}
+		}
+	}
+	if history := audit.versions.History("catalyst"); len(history) != 3 {
+		t.Fatalf("catalyst history %v, want 3 versions", history)
+	}
+}
@@ -0,0 +1,63 @@
+package explorer
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+const maxLineSize = 16 * 1024 * 1024
+
+// FileSource replays messages from a JSONL file, one Message per line.
+type FileSource struct {
+	file    *os.File
+	scanner *bufio.Scanner
+	line    int
+}
+
+func NewFileSource(path string) (*FileSource, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	return &FileSource{file: file, scanner: scanner}, nil
+}
+
+func (s *FileSource) Next(ctx context.Context) (*Message, error) {
+	for s.scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		s.line++
+		line := s.scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		message := &Message{}
+		if err := json.Unmarshal(line, message); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", s.file.Name(), s.line, err)
+		}
+		if message.Offset == 0 {
+			message.Offset = int64(s.line)
+		}
+		return message, nil
+	}
+	if err := s.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// Commit does nothing, a file is replayed from its start.
+func (s *FileSource) Commit(ctx context.Context, messages ...*Message) error {
+	return nil
+}
+
+func (s *FileSource) Close() error {
+	return s.file.Close()
+}
@@ -0,0 +1,63 @@
+package explorer
+
+import (
+	"context"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaSource consumes the subscriber topics with a consumer group. Offsets are only committed
+// through Commit, once the messages are processed.
+type KafkaSource struct {
+	reader *kafka.Reader
+}
+
+func NewKafkaSource(brokers []string, groupID string, topics []string) *KafkaSource {
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     brokers,
+		GroupID:     groupID,
+		GroupTopics: topics,
+		MinBytes:    1,
+		MaxBytes:    10e6,
+	})
+	return &KafkaSource{reader: reader}
+}
+
+func (s *KafkaSource) Next(ctx context.Context) (*Message, error) {
+	m, err := s.reader.FetchMessage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return fromKafka(m), nil
+}
+
+func (s *KafkaSource) Commit(ctx context.Context, messages ...*Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	kafkaMessages := make([]kafka.Message, len(messages))
+	for i, message := range messages {
+		kafkaMessages[i] = toKafka(message)
+	}
+	return s.reader.CommitMessages(ctx, kafkaMessages...)
+}
+
+func (s *KafkaSource) Close() error {
+	return s.reader.Close()
+}
+
+func fromKafka(m kafka.Message) *Message {
+	return &Message{
+		Topic:     m.Topic,
+		Key:       string(m.Key),
+		Value:     m.Value,
+		Partition: m.Partition,
+		Offset:    m.Offset,
+		Time:      m.Time,
+	}
+}
+
+// toKafka returns the position of message, what the reader commits.
+func toKafka(message *Message) kafka.Message {
+	return kafka.Message{Topic: message.Topic, Partition: message.Partition, Offset: message.Offset}
+}
@@ -0,0 +1,256 @@
+package explorer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go-node-audit/internal/stats"
+	"go-node-audit/pkg/ronin"
+
+	golog "github.com/ipfs/go-log"
+)
+
+const (
+	slowestBlocks = 10
+	trendBuckets  = 10
+)
+
+var log = golog.Logger("Explorer")
+
+// LatencyTracker measures how far the subscriber pipeline runs behind the chain,
+// as PublishedTime (unix milliseconds) minus the block timestamp, per entity type.
+type LatencyTracker struct {
+	mu        sync.Mutex
+	threshold time.Duration
+	retention int
+	alert     func(message string)
+	blocks    map[uint64]*BlockLatency
+	breached  bool
+}
+
+// BlockLatency holds the latencies of every record published for one block.
+type BlockLatency struct {
+	Number    uint64
+	Timestamp uint64
+	Latencies map[ronin.Entity][]time.Duration
+}
+
+func (b *BlockLatency) Max() time.Duration {
+	var max time.Duration
+	for _, latencies := range b.Latencies {
+		for _, latency := range latencies {
+			if latency > max {
+				max = latency
+			}
+		}
+	}
+	return max
+}
+
+// copy returns b with its own latencies, Observe keeps appending to the tracked ones.
+func (b *BlockLatency) copy() *BlockLatency {
+	latencies := make(map[ronin.Entity][]time.Duration, len(b.Latencies))
+	for entity, values := range b.Latencies {
+		latencies[entity] = append([]time.Duration(nil), values...)
+	}
+	return &BlockLatency{Number: b.Number, Timestamp: b.Timestamp, Latencies: latencies}
+}
+
+func (b *BlockLatency) all() []time.Duration {
+	all := make([]time.Duration, 0)
+	for _, latencies := range b.Latencies {
+		all = append(all, latencies...)
+	}
+	return all
+}
+
+// LatencyReport is the latency distribution of a block range.
+type LatencyReport struct {
+	From     uint64
+	To       uint64
+	Entities map[ronin.Entity]stats.Summary
+	Slowest  []*BlockLatency
+	Trend    []TrendBucket
+}
+
+// TrendBucket summarizes the latencies of a sub range of the report.
+type TrendBucket struct {
+	From    uint64
+	To      uint64
+	Summary stats.Summary
+}
+
+// NewLatencyTracker keeps the latest retention blocks. Evaluate alerts when the latency of a
+// block starts exceeding threshold and again when it recovers. A zero threshold disables alerting.
+func NewLatencyTracker(threshold time.Duration, retention int, alert func(message string)) *LatencyTracker {
+	return &LatencyTracker{
+		threshold: threshold,
+		retention: retention,
+		alert:     alert,
+		blocks:    make(map[uint64]*BlockLatency),
+	}
+}
+
+// The Observe helpers measure against the enclosing block, never the record's own timestamp,
+// so a record with a wrong timestamp does not skew the report.
+
+func (t *LatencyTracker) ObserveTransaction(tx *ronin.Transaction, block *ronin.Block) {
+	t.Observe(ronin.EntityTransaction, block.Number, block.BlockTimestamp(), tx.PublishedTime)
+}
+
+func (t *LatencyTracker) ObserveLog(l *ronin.Log, block *ronin.Block) {
+	t.Observe(ronin.EntityLog, block.Number, block.BlockTimestamp(), l.PublishedTime)
+}
+
+func (t *LatencyTracker) ObserveInternalTransaction(itx *ronin.InternalTransaction, block *ronin.Block) {
+	t.Observe(ronin.EntityInternalTransaction, block.Number, block.BlockTimestamp(), itx.PublishedTime)
+}
+
+func (t *LatencyTracker) ObserveDirtyAccount(account *ronin.DirtyAccount, block *ronin.Block) {
+	t.Observe(ronin.EntityDirtyAccount, block.Number, block.BlockTimestamp(), account.PublishedTime)
+}
+
+func (t *LatencyTracker) Observe(entity ronin.Entity, blockNumber, blockTimestamp uint64, publishedTime int64) {
+	if publishedTime == 0 {
+		return
+	}
+	latency := time.UnixMilli(publishedTime).Sub(time.Unix(int64(blockTimestamp), 0))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	block, ok := t.blocks[blockNumber]
+	if !ok {
+		if !t.makeRoom(blockNumber) {
+			return
+		}
+		block = &BlockLatency{Number: blockNumber, Timestamp: blockTimestamp, Latencies: make(map[ronin.Entity][]time.Duration)}
+		t.blocks[blockNumber] = block
+	}
+	block.Latencies[entity] = append(block.Latencies[entity], latency)
+}
+
+// makeRoom evicts the oldest block when the tracker is full. It returns false when
+// blockNumber itself is older than every retained block and should be dropped.
+func (t *LatencyTracker) makeRoom(blockNumber uint64) bool {
+	if t.retention <= 0 || len(t.blocks) < t.retention {
+		return true
+	}
+	oldest := blockNumber
+	for number := range t.blocks {
+		if number < oldest {
+			oldest = number
+		}
+	}
+	if oldest == blockNumber {
+		return false
+	}
+	delete(t.blocks, oldest)
+	return true
+}
+
+// Evaluate compares the max latency of blockNumber with the threshold and alerts
+// only when the pipeline starts or stops exceeding it.
+func (t *LatencyTracker) Evaluate(blockNumber uint64) {
+	if t.threshold <= 0 {
+		return
+	}
+	t.mu.Lock()
+	block, ok := t.blocks[blockNumber]
+	if !ok {
+		t.mu.Unlock()
+		return
+	}
+	latency := block.Max()
+	var message string
+	switch {
+	case latency > t.threshold && !t.breached:
+		t.breached = true
+		message = fmt.Sprintf("Ingestion latency is %s at block %d, above threshold %s", latency.Round(time.Millisecond), blockNumber, t.threshold)
+	case latency <= t.threshold && t.breached:
+		t.breached = false
+		message = fmt.Sprintf("Ingestion latency recovered to %s at block %d, threshold %s", latency.Round(time.Millisecond), blockNumber, t.threshold)
+	}
+	t.mu.Unlock()
+
+	if message == "" {
+		return
+	}
+	log.Warn(message)
+	if t.alert != nil {
+		t.alert(message)
+	}
+}
+
+// Report builds the latency report of the tracked blocks in [from, to].
+func (t *LatencyTracker) Report(from, to uint64) *LatencyReport {
+	t.mu.Lock()
+	blocks := make([]*BlockLatency, 0)
+	for number, block := range t.blocks {
+		if number >= from && number <= to {
+			blocks = append(blocks, block.copy())
+		}
+	}
+	t.mu.Unlock()
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].Number < blocks[j].Number })
+
+	report := &LatencyReport{From: from, To: to, Entities: make(map[ronin.Entity]stats.Summary)}
+	byEntity := make(map[ronin.Entity][]time.Duration)
+	for _, block := range blocks {
+		for entity, latencies := range block.Latencies {
+			byEntity[entity] = append(byEntity[entity], latencies...)
+		}
+	}
+	for entity, latencies := range byEntity {
+		report.Entities[entity] = stats.Summarize(latencies)
+	}
+
+	slowest := make([]*BlockLatency, len(blocks))
+	copy(slowest, blocks)
+	sort.SliceStable(slowest, func(i, j int) bool { return slowest[i].Max() > slowest[j].Max() })
+	if len(slowest) > slowestBlocks {
+		slowest = slowest[:slowestBlocks]
+	}
+	report.Slowest = slowest
+
+	if len(blocks) == 0 {
+		return report
+	}
+	first, last := blocks[0].Number, blocks[len(blocks)-1].Number
+	size := (last-first)/trendBuckets + 1
+	var latencies []time.Duration
+	for i, block := range blocks {
+		latencies = append(latencies, block.all()...)
+		start := first + (block.Number-first)/size*size
+		if i == len(blocks)-1 || blocks[i+1].Number >= start+size {
+			report.Trend = append(report.Trend, TrendBucket{From: start, To: start + size - 1, Summary: stats.Summarize(latencies)})
+			latencies = nil
+		}
+	}
+
+	return report
+}
+
+func (r *LatencyReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Ingestion latency for blocks %d-%d\n", r.From, r.To)
+	entities := make([]string, 0, len(r.Entities))
+	for entity := range r.Entities {
+		entities = append(entities, string(entity))
+	}
+	sort.Strings(entities)
+	for _, entity := range entities {
+		fmt.Fprintf(&b, "  %-14s %s\n", entity, r.Entities[ronin.Entity(entity)])
+	}
+	b.WriteString("Slowest blocks\n")
+	for _, block := range r.Slowest {
+		fmt.Fprintf(&b, "  %d max=%s\n", block.Number, block.Max())
+	}
+	b.WriteString("Trend\n")
+	for _, bucket := range r.Trend {
+		fmt.Fprintf(&b, "  %d-%d %s\n", bucket.From, bucket.To, bucket.Summary)
+	}
+	return b.String()
+}
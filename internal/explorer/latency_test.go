@@ -0,0 +1,103 @@
+package explorer
+
+import (
+	"testing"
+	"time"
+
+	"go-node-audit/pkg/ronin"
+)
+
+// published returns the PublishedTime of a record seen latency after blockTimestamp.
+func published(blockTimestamp uint64, latency time.Duration) int64 {
+	return time.Unix(int64(blockTimestamp), 0).Add(latency).UnixMilli()
+}
+
+func TestLatencyUsesBlockTimestamp(t *testing.T) {
+	tracker := NewLatencyTracker(0, 0, nil)
+	block := &ronin.Block{Number: 10, Timestamp: 1000}
+	// the tx carries the timestamp of a neighbouring block
+	tx := &ronin.Transaction{BlockNumber: 10, TimeStamp: 997, PublishedTime: published(1000, 2*time.Second)}
+	tracker.ObserveTransaction(tx, block)
+
+	report := tracker.Report(10, 10)
+	if got := report.Entities[ronin.EntityTransaction].Max; got != 2*time.Second {
+		t.Fatalf("latency = %s, want 2s", got)
+	}
+}
+
+func TestLatencyRetention(t *testing.T) {
+	tracker := NewLatencyTracker(0, 2, nil)
+	tracker.Observe(ronin.EntityLog, 10, 1000, published(1000, time.Second))
+	tracker.Observe(ronin.EntityLog, 11, 1003, published(1003, time.Second))
+	// older than every retained block, dropped
+	tracker.Observe(ronin.EntityLog, 5, 985, published(985, time.Second))
+	if _, ok := tracker.blocks[5]; ok || len(tracker.blocks) != 2 {
+		t.Fatalf("late block retained: %v", tracker.blocks)
+	}
+	// newer block evicts the oldest
+	tracker.Observe(ronin.EntityLog, 12, 1006, published(1006, time.Second))
+	if _, ok := tracker.blocks[10]; ok || len(tracker.blocks) != 2 {
+		t.Fatalf("oldest block not evicted: %v", tracker.blocks)
+	}
+}
+
+func TestLatencyTrend(t *testing.T) {
+	tracker := NewLatencyTracker(0, 0, nil)
+	for number := uint64(0); number < 20; number++ {
+		timestamp := 1000 + number*3
+		tracker.Observe(ronin.EntityTransaction, number, timestamp, published(timestamp, time.Duration(number+1)*time.Second))
+	}
+
+	report := tracker.Report(0, 19)
+	if len(report.Trend) != trendBuckets {
+		t.Fatalf("trend has %d buckets, want %d", len(report.Trend), trendBuckets)
+	}
+	for i, bucket := range report.Trend {
+		if bucket.From != uint64(i*2) || bucket.To != uint64(i*2+1) || bucket.Summary.Count != 2 {
+			t.Fatalf("bucket %d = %d-%d count %d", i, bucket.From, bucket.To, bucket.Summary.Count)
+		}
+		if want := time.Duration(i*2+2) * time.Second; bucket.Summary.Max != want {
+			t.Fatalf("bucket %d max = %s, want %s", i, bucket.Summary.Max, want)
+		}
+	}
+	if len(report.Slowest) != slowestBlocks || report.Slowest[0].Number != 19 {
+		t.Fatalf("slowest blocks = %v", report.Slowest)
+	}
+}
+
+func TestLatencyAlertIsEdgeTriggered(t *testing.T) {
+	alerts := make([]string, 0)
+	tracker := NewLatencyTracker(5*time.Second, 0, func(message string) { alerts = append(alerts, message) })
+	latencies := []time.Duration{time.Second, 10 * time.Second, 11 * time.Second, 12 * time.Second, time.Second, 2 * time.Second}
+	for number, latency := range latencies {
+		timestamp := uint64(1000 + number*3)
+		tracker.Observe(ronin.EntityTransaction, uint64(number), timestamp, published(timestamp, latency))
+		tracker.Evaluate(uint64(number))
+	}
+	if len(alerts) != 2 {
+		t.Fatalf("got %d alerts, want breach and recovery: %v", len(alerts), alerts)
+	}
+}
+
+func TestLatencyReportWhileObserving(t *testing.T) {
+	tracker := NewLatencyTracker(0, 0, nil)
+	tracker.Observe(ronin.EntityLog, 1, 1000, published(1000, time.Second))
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				tracker.Observe(ronin.EntityLog, 1, 1000, published(1000, time.Second))
+				tracker.Observe(ronin.EntityTransaction, 1, 1000, published(1000, time.Second))
+			}
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		_ = tracker.Report(1, 1).String()
+	}
+	close(stop)
+	<-done
+}
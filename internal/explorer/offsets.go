@@ -0,0 +1,83 @@
+package explorer
+
+import (
+	"sync"
+)
+
+type partition struct {
+	topic     string
+	partition int
+}
+
+// offsetTracker decides how far every partition can be committed. A message stays pending
+// until the block it belongs to is reported, so a restart consumes again every block whose
+// verification was not reported.
+type offsetTracker struct {
+	mu        sync.Mutex
+	consumed  map[partition]int64
+	committed map[partition]int64
+	// lowest offset per partition of the messages of every unreported block
+	pending map[uint64]map[partition]int64
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{
+		consumed:  make(map[partition]int64),
+		committed: make(map[partition]int64),
+		pending:   make(map[uint64]map[partition]int64),
+	}
+}
+
+// consume records message as the latest one of its partition.
+func (t *offsetTracker) consume(message *Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := partition{message.Topic, message.Partition}
+	if _, ok := t.consumed[key]; !ok {
+		// the group already stands before the first message
+		t.committed[key] = message.Offset - 1
+	}
+	t.consumed[key] = message.Offset
+}
+
+// hold keeps message pending until block is released.
+func (t *offsetTracker) hold(block uint64, message *Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	lowest, ok := t.pending[block]
+	if !ok {
+		lowest = make(map[partition]int64)
+		t.pending[block] = lowest
+	}
+	key := partition{message.Topic, message.Partition}
+	if offset, ok := lowest[key]; !ok || message.Offset < offset {
+		lowest[key] = message.Offset
+	}
+}
+
+func (t *offsetTracker) release(block uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.pending, block)
+}
+
+// committable returns the last message of every partition that can be committed since the
+// previous call, the one before the first pending message or else the last consumed one.
+func (t *offsetTracker) committable() []*Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	messages := make([]*Message, 0)
+	for key, offset := range t.consumed {
+		for _, lowest := range t.pending {
+			if pending, ok := lowest[key]; ok && pending <= offset {
+				offset = pending - 1
+			}
+		}
+		if offset <= t.committed[key] {
+			continue
+		}
+		t.committed[key] = offset
+		messages = append(messages, &Message{Topic: key.topic, Partition: key.partition, Offset: offset})
+	}
+	return messages
+}
@@ -0,0 +1,34 @@
+package explorer
+
+import (
+	"testing"
+)
+
+func TestOffsetTrackerHoldsUnreportedBlocks(t *testing.T) {
+	tracker := newOffsetTracker()
+	consume := func(block uint64, offset int64) {
+		message := &Message{Topic: "logs", Offset: offset}
+		tracker.consume(message)
+		tracker.hold(block, message)
+	}
+	// the records of blocks 10 and 11 interleave in the partition
+	consume(10, 4)
+	consume(11, 5)
+	consume(10, 6)
+	consume(11, 7)
+
+	if got := tracker.committable(); len(got) != 0 {
+		t.Fatalf("committable %v before any block is reported", got)
+	}
+	tracker.release(10)
+	if got := tracker.committable(); len(got) != 1 || got[0].Offset != 4 {
+		t.Fatalf("committable %v, want offset 4 before the first message of block 11", got)
+	}
+	tracker.release(11)
+	if got := tracker.committable(); len(got) != 1 || got[0].Offset != 7 {
+		t.Fatalf("committable %v, want the last consumed offset 7", got)
+	}
+	if got := tracker.committable(); len(got) != 0 {
+		t.Fatalf("committable %v, want nothing new", got)
+	}
+}
@@ -0,0 +1,78 @@
+package explorer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"go-node-audit/pkg/rpc"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Orphan is a stored record whose block is not canonical at its number, or a log the node
+// flagged as removed. It must be deleted from the explorer store.
+type Orphan struct {
+	RecordRef
+	Canonical common.Hash `json:"canonical"`
+}
+
+func (o Orphan) String() string {
+	if o.Removed {
+		return fmt.Sprintf("%s %s of block %d is flagged removed", o.Entity, o.ID, o.BlockNumber)
+	}
+	return fmt.Sprintf("%s %s references block %s, canonical block %d is %s", o.Entity, o.ID, o.BlockHash.Hex(), o.BlockNumber, o.Canonical.Hex())
+}
+
+// OrphanAuditor joins the block hashes of stored records against the canonical chain.
+type OrphanAuditor struct {
+	store     Store
+	client    *rpc.JsonRPCClient
+	batchSize int
+}
+
+func NewOrphanAuditor(store Store, client *rpc.JsonRPCClient, batchSize int) *OrphanAuditor {
+	return &OrphanAuditor{store: store, client: client, batchSize: batchSize}
+}
+
+// Audit returns the orphaned records of blocks in [from, to].
+func (a *OrphanAuditor) Audit(ctx context.Context, from, to uint64) ([]Orphan, error) {
+	records, err := a.store.Records(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	numbers := make([]uint64, 0)
+	seen := make(map[uint64]bool)
+	for _, record := range records {
+		if !seen[record.BlockNumber] {
+			seen[record.BlockNumber] = true
+			numbers = append(numbers, record.BlockNumber)
+		}
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+
+	canonical := make(map[uint64]common.Hash, len(numbers))
+	for _, chunk := range chunks(len(numbers), a.batchSize) {
+		part := numbers[chunk[0]:chunk[1]]
+		blocks, err := a.client.BatchGetBlockByNumber(ctx, part)
+		if err != nil {
+			return nil, fmt.Errorf("cannot fetch canonical blocks %d-%d: %w", part[0], part[len(part)-1], err)
+		}
+		for i, block := range blocks {
+			if block == nil {
+				return nil, fmt.Errorf("canonical block %d: %w", part[i], rpc.ErrNotFound)
+			}
+			canonical[part[i]] = block.Hash
+		}
+	}
+
+	orphans := make([]Orphan, 0)
+	for _, record := range records {
+		hash := canonical[record.BlockNumber]
+		if record.Removed || record.BlockHash != hash {
+			orphans = append(orphans, Orphan{RecordRef: record, Canonical: hash})
+		}
+	}
+	return orphans, nil
+}
@@ -0,0 +1,83 @@
+package explorer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-node-audit/pkg/ronin"
+	"go-node-audit/pkg/rpc"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// canonicalNode answers batched eth_getBlockByNumber with hash {number}.
+func canonicalNode(t *testing.T) *rpc.JsonRPCClient {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var requests []rpc.ServerRequest
+		if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		responses := make([]rpc.ServerResponse[*rpc.BlockResponse], len(requests))
+		for i, request := range requests {
+			var params []interface{}
+			json.Unmarshal(*request.Params, &params)
+			number, _ := hexutil.DecodeUint64(params[0].(string))
+			responses[i] = rpc.ServerResponse[*rpc.BlockResponse]{
+				Version: rpc.JSONRPCVersion,
+				ID:      request.ID,
+				Result:  &rpc.BlockResponse{Number: hexutil.Uint64(number), Hash: canonicalHash(number)},
+			}
+		}
+		json.NewEncoder(w).Encode(responses)
+	}))
+	t.Cleanup(server.Close)
+	return rpc.NewRPCClient(rpc.JsonRpcUrl(server.URL))
+}
+
+func canonicalHash(number uint64) common.Hash {
+	return common.Hash{byte(number)}
+}
+
+func TestOrphanAudit(t *testing.T) {
+	sidechain := common.Hash{0xff}
+	store := NewMemoryStore(0)
+	store.Add(
+		RecordRef{Entity: ronin.EntityBlock, ID: "block-1", BlockNumber: 1, BlockHash: canonicalHash(1)},
+		RecordRef{Entity: ronin.EntityTransaction, ID: "tx-1", BlockNumber: 1, BlockHash: canonicalHash(1)},
+		RecordRef{Entity: ronin.EntityTransaction, ID: "tx-2", BlockNumber: 2, BlockHash: sidechain},
+		RecordRef{Entity: ronin.EntityLog, ID: "log-2", BlockNumber: 2, BlockHash: canonicalHash(2), Removed: true},
+		RecordRef{Entity: ronin.EntityInternalTransaction, ID: "itx-3", BlockNumber: 3, BlockHash: canonicalHash(2)},
+	)
+
+	orphans, err := NewOrphanAuditor(store, canonicalNode(t), 2).Audit(context.Background(), 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"tx-2", "log-2", "itx-3"}
+	if len(orphans) != len(want) {
+		t.Fatalf("got %d orphans, want %v: %v", len(orphans), want, orphans)
+	}
+	for i, id := range want {
+		if orphans[i].ID != id {
+			t.Errorf("orphan %d is %s, want %s", i, orphans[i].ID, id)
+		}
+	}
+	if orphans[0].Canonical != canonicalHash(2) {
+		t.Errorf("canonical hash %s, want %s", orphans[0].Canonical.Hex(), canonicalHash(2).Hex())
+	}
+}
+
+func TestMemoryStoreRetention(t *testing.T) {
+	store := NewMemoryStore(2)
+	for number := uint64(1); number <= 3; number++ {
+		store.Add(RecordRef{Entity: ronin.EntityBlock, BlockNumber: number})
+	}
+	if from, to, _ := store.Range(); from != 2 || to != 3 {
+		t.Fatalf("store holds blocks %d-%d, want 2-3", from, to)
+	}
+}
@@ -0,0 +1,101 @@
+package explorer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"go-node-audit/config"
+	"go-node-audit/pkg/ronin"
+)
+
+type publishedRecord struct {
+	entity    ronin.Entity
+	published int64
+}
+
+// ReplayLatency feeds the records of a finite source to tracker and evaluates every block in
+// order. Records are measured once the source is exhausted, their block may come after them.
+// It returns the block range seen.
+func ReplayLatency(ctx context.Context, source MessageSource, cfg config.Explorer, tracker *LatencyTracker) (uint64, uint64, error) {
+	topics := map[string]ronin.Entity{
+		cfg.BlockTopic:        ronin.EntityBlock,
+		cfg.TransactionTopic:  ronin.EntityTransaction,
+		cfg.LogTopic:          ronin.EntityLog,
+		cfg.InternalTxTopic:   ronin.EntityInternalTransaction,
+		cfg.DirtyAccountTopic: ronin.EntityDirtyAccount,
+	}
+	blocks := make(map[uint64]*ronin.Block)
+	records := make(map[uint64][]publishedRecord)
+
+	for {
+		message, err := source.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		entity, ok := topics[message.Topic]
+		if !ok {
+			log.Warnf("Skipping message from unknown topic %s", message.Topic)
+			continue
+		}
+
+		var blockNumber uint64
+		var published int64
+		switch entity {
+		case ronin.EntityBlock:
+			block := &ronin.Block{}
+			if err = json.Unmarshal(message.Value, block); err == nil {
+				blocks[block.Number] = block
+				continue
+			}
+		case ronin.EntityTransaction:
+			var tx ronin.Transaction
+			err = json.Unmarshal(message.Value, &tx)
+			blockNumber, published = tx.BlockNumber, tx.PublishedTime
+		case ronin.EntityLog:
+			var l ronin.Log
+			err = json.Unmarshal(message.Value, &l)
+			blockNumber, published = l.BlockNumber, l.PublishedTime
+		case ronin.EntityInternalTransaction:
+			var itx ronin.InternalTransaction
+			err = json.Unmarshal(message.Value, &itx)
+			blockNumber, published = itx.Height, itx.PublishedTime
+		case ronin.EntityDirtyAccount:
+			var account ronin.DirtyAccount
+			err = json.Unmarshal(message.Value, &account)
+			blockNumber, published = account.BlockNumber, account.PublishedTime
+		}
+		if err != nil {
+			return 0, 0, fmt.Errorf("offset %d: cannot decode %s: %w", message.Offset, entity, err)
+		}
+		records[blockNumber] = append(records[blockNumber], publishedRecord{entity: entity, published: published})
+	}
+
+	numbers := make([]uint64, 0, len(blocks))
+	for number := range blocks {
+		numbers = append(numbers, number)
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+	for _, number := range numbers {
+		block := blocks[number]
+		for _, record := range records[number] {
+			tracker.Observe(record.entity, number, block.BlockTimestamp(), record.published)
+		}
+		tracker.Evaluate(number)
+		delete(records, number)
+	}
+	for number, pending := range records {
+		log.Warnf("Skipping %d records of block %d missing from the dump", len(pending), number)
+	}
+
+	if len(numbers) == 0 {
+		return 0, 0, nil
+	}
+	return numbers[0], numbers[len(numbers)-1], nil
+}
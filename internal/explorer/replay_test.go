@@ -0,0 +1,56 @@
+package explorer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-node-audit/config"
+	"go-node-audit/pkg/ronin"
+)
+
+func TestReplayLatency(t *testing.T) {
+	cfg := config.Explorer{BlockTopic: "blocks", TransactionTopic: "transactions", LogTopic: "logs"}
+	// the log comes before its block, the tx of block 12 has no block in the dump
+	dump := `{"topic": "logs", "value": {"blockNumber": 11, "publishedTime": 1000000004000}}
+{"topic": "blocks", "value": {"number": 10, "timestamp": "0x3b9aca00"}}
+{"topic": "transactions", "value": {"blockNumber": 10, "publishedTime": 1000000002000}}
+
+{"topic": "blocks", "value": {"number": 11, "timestamp": "0x3b9aca03"}}
+{"topic": "transactions", "value": {"blockNumber": 12, "publishedTime": 1000000009000}}
+`
+	tracker := NewLatencyTracker(0, 0, nil)
+	from, to, err := ReplayLatency(context.Background(), replaySource(t, dump), cfg, tracker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != 10 || to != 11 {
+		t.Fatalf("range %d-%d, want 10-11", from, to)
+	}
+	report := tracker.Report(from, to)
+	if got := report.Entities[ronin.EntityTransaction]; got.Count != 1 || got.Max != 2*time.Second {
+		t.Fatalf("transaction latency %s, want one of 2s", got)
+	}
+	if got := report.Entities[ronin.EntityLog]; got.Count != 1 || got.Max != time.Second {
+		t.Fatalf("log latency %s, want one of 1s", got)
+	}
+
+	if _, _, err := ReplayLatency(context.Background(), replaySource(t, "{\n"), cfg, tracker); err == nil {
+		t.Fatal("malformed line accepted")
+	}
+}
+
+func replaySource(t *testing.T, dump string) *FileSource {
+	path := filepath.Join(t.TempDir(), "dump.jsonl")
+	if err := os.WriteFile(path, []byte(dump), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	source, err := NewFileSource(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { source.Close() })
+	return source
+}
@@ -0,0 +1,26 @@
+package explorer
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Message is a single record read from a subscriber topic.
+type Message struct {
+	Topic     string          `json:"topic"`
+	Key       string          `json:"key,omitempty"`
+	Value     json.RawMessage `json:"value"`
+	Partition int             `json:"partition,omitempty"`
+	Offset    int64           `json:"offset,omitempty"`
+	Time      time.Time       `json:"time,omitempty"`
+}
+
+// MessageSource delivers subscriber messages in the order they were published.
+// Next returns io.EOF once a finite source is exhausted. Commit marks messages, and the ones
+// before them in their partitions, as processed.
+type MessageSource interface {
+	Next(ctx context.Context) (*Message, error)
+	Commit(ctx context.Context, messages ...*Message) error
+	Close() error
+}
@@ -0,0 +1,55 @@
+package explorer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestFileSource(t *testing.T) {
+	source := replaySource(t, `{"topic": "blocks", "value": {"number": 1}}
+
+{"topic": "logs", "value": {}, "offset": 42}
+{
+`)
+	ctx := context.Background()
+
+	first, err := source.Next(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Topic != "blocks" || first.Offset != 1 || string(first.Value) != `{"number": 1}` {
+		t.Fatalf("first message %+v", first)
+	}
+	// the blank line is skipped but counted
+	second, err := source.Next(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Topic != "logs" || second.Offset != 42 {
+		t.Fatalf("second message %+v, want its own offset", second)
+	}
+	if _, err := source.Next(ctx); err == nil || errors.Is(err, io.EOF) {
+		t.Fatalf("malformed line returned %v", err)
+	}
+	if _, err := source.Next(ctx); !errors.Is(err, io.EOF) {
+		t.Fatalf("got %v at the end of the file, want io.EOF", err)
+	}
+}
+
+func TestKafkaMessage(t *testing.T) {
+	published := time.Unix(1700000000, 0)
+	m := kafka.Message{Topic: "logs", Partition: 3, Offset: 17, Key: []byte("0xabc"), Value: []byte(`{}`), Time: published}
+
+	message := fromKafka(m)
+	if message.Topic != "logs" || message.Partition != 3 || message.Offset != 17 || message.Key != "0xabc" || string(message.Value) != "{}" || !message.Time.Equal(published) {
+		t.Fatalf("fromKafka = %+v", message)
+	}
+	if got := toKafka(message); got.Topic != m.Topic || got.Partition != m.Partition || got.Offset != m.Offset {
+		t.Fatalf("toKafka = %+v, want the position of %+v", got, m)
+	}
+}
@@ -0,0 +1,165 @@
+package explorer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"go-node-audit/config"
+	"go-node-audit/pkg/ronin"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// RecordRef identifies a stored explorer record by the block it claims to belong to.
+type RecordRef struct {
+	Entity      ronin.Entity `json:"entity"`
+	ID          string       `json:"id"`
+	BlockNumber uint64       `json:"blockNumber"`
+	BlockHash   common.Hash  `json:"blockHash"`
+	Removed     bool         `json:"removed,omitempty"`
+}
+
+// Store is the explorer data the orphan audit runs against.
+type Store interface {
+	// Records returns the records of blocks in [from, to].
+	Records(from, to uint64) ([]RecordRef, error)
+}
+
+// MemoryStore keeps the records of the latest retention blocks.
+type MemoryStore struct {
+	mu        sync.Mutex
+	retention int
+	records   map[uint64][]RecordRef
+}
+
+func NewMemoryStore(retention int) *MemoryStore {
+	return &MemoryStore{retention: retention, records: make(map[uint64][]RecordRef)}
+}
+
+func (s *MemoryStore) Add(records ...RecordRef) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, record := range records {
+		s.records[record.BlockNumber] = append(s.records[record.BlockNumber], record)
+	}
+	if s.retention <= 0 || len(s.records) <= s.retention {
+		return
+	}
+	numbers := s.numbers()
+	for _, number := range numbers[:len(numbers)-s.retention] {
+		delete(s.records, number)
+	}
+}
+
+func (s *MemoryStore) Records(from, to uint64) ([]RecordRef, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records := make([]RecordRef, 0)
+	for _, number := range s.numbers() {
+		if number >= from && number <= to {
+			records = append(records, s.records[number]...)
+		}
+	}
+	return records, nil
+}
+
+// Range returns the lowest and highest block held, false when the store is empty.
+func (s *MemoryStore) Range() (uint64, uint64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	numbers := s.numbers()
+	if len(numbers) == 0 {
+		return 0, 0, false
+	}
+	return numbers[0], numbers[len(numbers)-1], true
+}
+
+func (s *MemoryStore) numbers() []uint64 {
+	numbers := make([]uint64, 0, len(s.records))
+	for number := range s.records {
+		numbers = append(numbers, number)
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+	return numbers
+}
+
+// LoadStore reads every block, tx, log and internal tx message of source into a MemoryStore,
+// used to audit an exported dump of the explorer topics.
+func LoadStore(ctx context.Context, cfg config.Explorer, source MessageSource) (*MemoryStore, error) {
+	topics := map[string]ronin.Entity{
+		cfg.BlockTopic:       ronin.EntityBlock,
+		cfg.TransactionTopic: ronin.EntityTransaction,
+		cfg.LogTopic:         ronin.EntityLog,
+		cfg.InternalTxTopic:  ronin.EntityInternalTransaction,
+	}
+	store := NewMemoryStore(0)
+	for {
+		message, err := source.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			return store, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		entity, ok := topics[message.Topic]
+		if !ok {
+			continue
+		}
+		record, err := recordRef(entity, message.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%s offset %d: %w", message.Topic, message.Offset, err)
+		}
+		store.Add(record)
+	}
+}
+
+func recordRef(entity ronin.Entity, value json.RawMessage) (RecordRef, error) {
+	switch entity {
+	case ronin.EntityBlock:
+		var block ronin.Block
+		if err := json.Unmarshal(value, &block); err != nil {
+			return RecordRef{}, err
+		}
+		return blockRef(&block), nil
+	case ronin.EntityTransaction:
+		var tx ronin.Transaction
+		if err := json.Unmarshal(value, &tx); err != nil {
+			return RecordRef{}, err
+		}
+		return transactionRef(&tx), nil
+	case ronin.EntityLog:
+		var l ronin.Log
+		if err := json.Unmarshal(value, &l); err != nil {
+			return RecordRef{}, err
+		}
+		return logRef(&l), nil
+	case ronin.EntityInternalTransaction:
+		var itx ronin.InternalTransaction
+		if err := json.Unmarshal(value, &itx); err != nil {
+			return RecordRef{}, err
+		}
+		return internalTransactionRef(&itx), nil
+	}
+	return RecordRef{}, fmt.Errorf("entity %s is not stored", entity)
+}
+
+func blockRef(block *ronin.Block) RecordRef {
+	return RecordRef{Entity: ronin.EntityBlock, ID: block.Hash.Hex(), BlockNumber: block.Number, BlockHash: block.Hash}
+}
+
+func transactionRef(tx *ronin.Transaction) RecordRef {
+	return RecordRef{Entity: ronin.EntityTransaction, ID: tx.Hash.Hex(), BlockNumber: tx.BlockNumber, BlockHash: tx.BlockHash}
+}
+
+func logRef(l *ronin.Log) RecordRef {
+	return RecordRef{Entity: ronin.EntityLog, ID: fmt.Sprintf("%s#%d", l.TxHash.Hex(), l.Index), BlockNumber: l.BlockNumber, BlockHash: l.BlockHash, Removed: l.Removed}
+}
+
+func internalTransactionRef(itx *ronin.InternalTransaction) RecordRef {
+	return RecordRef{Entity: ronin.EntityInternalTransaction, ID: fmt.Sprintf("%s#%d", itx.TransactionHash.Hex(), itx.Index), BlockNumber: itx.Height, BlockHash: itx.BlockHash}
+}
@@ -0,0 +1,863 @@
+package explorer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-node-audit/config"
+	"go-node-audit/internal/alert"
+	"go-node-audit/pkg/ronin"
+	"go-node-audit/pkg/rpc"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+const (
+	maxGapBlocks    = 10000
+	maxAlertedIssue = 5
+)
+
+// Issue kinds, each one is alerted when it starts and when it stops.
+const (
+	IssueMissingBlock       = "missing_block"
+	IssueMissingRecord      = "missing_record"
+	IssueDuplicate          = "duplicate"
+	IssueOutOfOrder         = "out_of_order"
+	IssueLate               = "late"
+	IssueHeaderHash         = "header_hash"
+	IssueParentHash         = "parent_hash"
+	IssueNotCanonical       = "not_canonical"
+	IssueTimestamp          = "timestamp"
+	IssueContractAddress    = "contract_address"
+	IssueUnverifiedCreate2  = "unverified_create2"
+	IssueTransactionDiff    = "transaction_mismatch"
+	IssueLogDiff            = "log_mismatch"
+	IssueAccountDiff        = "account_mismatch"
+	IssueVerificationFailed = "verification_failed"
+	IssueOrphaned           = "orphaned"
+)
+
+var issueKinds = []string{
+	IssueMissingBlock, IssueMissingRecord, IssueDuplicate, IssueOutOfOrder, IssueHeaderHash, IssueParentHash,
+	IssueNotCanonical, IssueTimestamp, IssueContractAddress, IssueUnverifiedCreate2, IssueTransactionDiff, IssueLogDiff,
+	IssueAccountDiff, IssueVerificationFailed,
+}
+
+// StreamAuditor checks subscriber messages as they arrive for duplicate, out-of-order and
+// missing messages per block. Once a block is CompletionDepth blocks behind the stream head,
+// its records are verified against the chain in batches by a pool of workers.
+type StreamAuditor struct {
+	cfg        config.Explorer
+	client     *rpc.JsonRPCClient
+	latency    *LatencyTracker
+	conditions *alert.Conditions
+	topics     map[string]ronin.Entity
+
+	blocks    map[uint64]*blockState
+	first     uint64
+	highest   uint64
+	finalized uint64
+	started   bool
+	late      int
+	lateBlock uint64
+
+	verify  chan *blockState
+	workers sync.WaitGroup
+	// verified blocks, reported in finalize order by a single goroutine
+	reports   chan *blockState
+	sequence  uint64
+	lastIssue map[string]uint64
+	offsets   *offsetTracker
+
+	store         *MemoryStore
+	orphans       *OrphanAuditor
+	orphanRunning int32
+	reorged       bool
+}
+
+type issue struct {
+	kind    string
+	message string
+}
+
+type blockState struct {
+	number uint64
+	// finalize order
+	sequence uint64
+	block    *ronin.Block
+	// block hash of every record seen, by entity and id
+	seen     map[ronin.Entity]map[string]common.Hash
+	last     map[ronin.Entity]uint64
+	txs      []ronin.Transaction
+	logs     []ronin.Log
+	itxs     []ronin.InternalTransaction
+	accounts []ronin.DirtyAccount
+	issues   []issue
+}
+
+func NewStreamAuditor(cfg config.Explorer, client *rpc.JsonRPCClient, notify func(message string)) *StreamAuditor {
+	store := NewMemoryStore(cfg.StoreRetention)
+	return &StreamAuditor{
+		cfg:        cfg,
+		client:     client,
+		latency:    NewLatencyTracker(cfg.MaxIngestionLatency, cfg.LatencyRetention, notify),
+		conditions: alert.NewConditions(notify),
+		topics: map[string]ronin.Entity{
+			cfg.BlockTopic:        ronin.EntityBlock,
+			cfg.TransactionTopic:  ronin.EntityTransaction,
+			cfg.LogTopic:          ronin.EntityLog,
+			cfg.InternalTxTopic:   ronin.EntityInternalTransaction,
+			cfg.DirtyAccountTopic: ronin.EntityDirtyAccount,
+		},
+		blocks:    make(map[uint64]*blockState),
+		lastIssue: make(map[string]uint64),
+		offsets:   newOffsetTracker(),
+		store:     store,
+		orphans:   NewOrphanAuditor(store, client, cfg.VerifyBatchSize),
+	}
+}
+
+// Topics returns the topics the auditor understands.
+func (a *StreamAuditor) Topics() []string {
+	return []string{a.cfg.BlockTopic, a.cfg.TransactionTopic, a.cfg.LogTopic, a.cfg.InternalTxTopic, a.cfg.DirtyAccountTopic}
+}
+
+func (a *StreamAuditor) Latency() *LatencyTracker {
+	return a.latency
+}
+
+// Run consumes source until it is exhausted or ctx is cancelled.
+func (a *StreamAuditor) Run(ctx context.Context, source MessageSource) error {
+	workers := a.cfg.VerifyWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	a.verify = make(chan *blockState, workers)
+	a.reports = make(chan *blockState, workers)
+	reported := make(chan struct{})
+	go func() {
+		defer close(reported)
+		a.reportInOrder(ctx, source)
+	}()
+	for i := 0; i < workers; i++ {
+		a.workers.Add(1)
+		go func() {
+			defer a.workers.Done()
+			for state := range a.verify {
+				a.verifyBlock(ctx, state)
+				a.reports <- state
+			}
+		}()
+	}
+	defer func() {
+		close(a.verify)
+		a.workers.Wait()
+		close(a.reports)
+		<-reported
+	}()
+
+	lastReport := time.Now()
+	lastOrphanAudit := time.Now()
+	for {
+		message, err := source.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			a.flush()
+			a.logLatencyReport()
+			a.auditOrphans(ctx)
+			return nil
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		a.handle(message)
+		if a.cfg.LatencyReportPeriod > 0 && time.Since(lastReport) >= a.cfg.LatencyReportPeriod {
+			a.logLatencyReport()
+			lastReport = time.Now()
+		}
+		// a block replaced in the stream means the explorer may hold records of the old branch
+		if a.reorged || (a.cfg.OrphanAuditPeriod > 0 && time.Since(lastOrphanAudit) >= a.cfg.OrphanAuditPeriod) {
+			if atomic.CompareAndSwapInt32(&a.orphanRunning, 0, 1) {
+				a.reorged = false
+				lastOrphanAudit = time.Now()
+				a.workers.Add(1)
+				go func() {
+					defer a.workers.Done()
+					defer atomic.StoreInt32(&a.orphanRunning, 0)
+					a.auditOrphans(ctx)
+				}()
+			}
+		}
+	}
+}
+
+// auditOrphans checks every record held in the store against the canonical chain.
+func (a *StreamAuditor) auditOrphans(ctx context.Context) {
+	from, to, ok := a.store.Range()
+	if !ok {
+		return
+	}
+	orphans, err := a.orphans.Audit(ctx, from, to)
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		log.Errorf("Orphan audit of blocks %d-%d failed: %v", from, to, err)
+		return
+	}
+	if len(orphans) == 0 {
+		a.conditions.Recover(IssueOrphaned, fmt.Sprintf("Explorer holds no orphaned records in blocks %d-%d", from, to))
+		return
+	}
+	messages := make([]string, 0, maxAlertedIssue)
+	for i, orphan := range orphans {
+		log.Warnf("Orphaned record to clean up: %s", orphan)
+		if i < maxAlertedIssue {
+			messages = append(messages, orphan.String())
+		}
+	}
+	a.conditions.Breach(IssueOrphaned, fmt.Sprintf("Explorer holds %d orphaned records in blocks %d-%d: %s", len(orphans), from, to, strings.Join(messages, "; ")))
+}
+
+func (a *StreamAuditor) handle(message *Message) {
+	a.offsets.consume(message)
+	entity, ok := a.topics[message.Topic]
+	if !ok {
+		log.Warnf("Skipping message from unknown topic %s", message.Topic)
+		return
+	}
+
+	var err error
+	switch entity {
+	case ronin.EntityBlock:
+		var block ronin.Block
+		if err = json.Unmarshal(message.Value, &block); err == nil {
+			a.hold(block.Number, message)
+			a.handleBlock(&block)
+		}
+	case ronin.EntityTransaction:
+		var tx ronin.Transaction
+		if err = json.Unmarshal(message.Value, &tx); err == nil {
+			a.hold(tx.BlockNumber, message)
+			a.handleTransaction(&tx)
+		}
+	case ronin.EntityLog:
+		var l ronin.Log
+		if err = json.Unmarshal(message.Value, &l); err == nil {
+			a.hold(l.BlockNumber, message)
+			a.handleLog(&l)
+		}
+	case ronin.EntityInternalTransaction:
+		var itx ronin.InternalTransaction
+		if err = json.Unmarshal(message.Value, &itx); err == nil {
+			a.hold(itx.Height, message)
+			a.handleInternalTransaction(&itx)
+		}
+	case ronin.EntityDirtyAccount:
+		var account ronin.DirtyAccount
+		if err = json.Unmarshal(message.Value, &account); err == nil {
+			a.hold(account.BlockNumber, message)
+			a.handleDirtyAccount(&account)
+		}
+	}
+	if err != nil {
+		log.Errorf("Cannot decode %s message at %s/%d offset %d: %v", entity, message.Topic, message.Partition, message.Offset, err)
+	}
+}
+
+// hold keeps message uncommitted until its block is reported, when the block is audited.
+func (a *StreamAuditor) hold(number uint64, message *Message) {
+	if !a.started || (number >= a.first && number > a.finalized) {
+		a.offsets.hold(number, message)
+	}
+}
+
+func (a *StreamAuditor) handleBlock(block *ronin.Block) {
+	state, ok := a.state(ronin.EntityBlock, block.Number)
+	if !ok || !a.markSeen(state, ronin.EntityBlock, block.Hash.Hex(), block.Hash) {
+		return
+	}
+	if state.block != nil {
+		// the subscriber publishes the new branch of a reorg again, the explorer may still
+		// hold records of the old one
+		log.Infof("Block %d replaced by %s, was %s", block.Number, block.Hash.Hex(), state.block.Hash.Hex())
+		state.replace(block.Hash)
+		a.reorged = true
+	} else if a.started && block.Number < a.highest {
+		state.add(IssueOutOfOrder, "block %d arrived after block %d", block.Number, a.highest)
+	}
+	state.block = block
+
+	if err := block.VerifyHash(); err != nil {
+		state.add(IssueHeaderHash, "%v", err)
+	}
+	if parent, ok := a.blocks[block.Number-1]; ok && parent.block != nil {
+		if parent.block.Hash != block.ParentHash {
+			state.add(IssueParentHash, "block %d parent hash %s does not match block %d hash %s", block.Number, block.ParentHash.Hex(), parent.number, parent.block.Hash.Hex())
+		}
+		for _, mismatch := range ronin.CheckBlockTimestamps([]ronin.Block{*parent.block, *block}) {
+			state.add(IssueTimestamp, "%s", mismatch)
+		}
+	}
+
+	a.advance(block.Number)
+}
+
+func (a *StreamAuditor) handleTransaction(tx *ronin.Transaction) {
+	state, ok := a.state(ronin.EntityTransaction, tx.BlockNumber)
+	if !ok || !a.markSeen(state, ronin.EntityTransaction, tx.Hash.Hex(), tx.BlockHash) {
+		return
+	}
+	a.checkOrder(state, ronin.EntityTransaction, uint64(tx.TransactionIndex), tx.Hash.Hex())
+	state.txs = append(state.txs, *tx)
+
+	if err := tx.VerifyContractAddress(); err != nil {
+		state.add(IssueContractAddress, "%v", err)
+	}
+}
+
+func (a *StreamAuditor) handleLog(l *ronin.Log) {
+	state, ok := a.state(ronin.EntityLog, l.BlockNumber)
+	if !ok || !a.markSeen(state, ronin.EntityLog, fmt.Sprint(l.Index), l.BlockHash) {
+		return
+	}
+	a.checkOrder(state, ronin.EntityLog, uint64(l.Index), fmt.Sprintf("%s#%d", l.TxHash.Hex(), l.Index))
+	state.logs = append(state.logs, *l)
+}
+
+func (a *StreamAuditor) handleInternalTransaction(itx *ronin.InternalTransaction) {
+	state, ok := a.state(ronin.EntityInternalTransaction, itx.Height)
+	if !ok || !a.markSeen(state, ronin.EntityInternalTransaction, fmt.Sprint(itx.Index), itx.BlockHash) {
+		return
+	}
+	a.checkOrder(state, ronin.EntityInternalTransaction, uint64(itx.Index), fmt.Sprintf("%s#%d", itx.TransactionHash.Hex(), itx.Index))
+	state.itxs = append(state.itxs, *itx)
+}
+
+func (a *StreamAuditor) handleDirtyAccount(account *ronin.DirtyAccount) {
+	state, ok := a.state(ronin.EntityDirtyAccount, account.BlockNumber)
+	if !ok || !a.markSeen(state, ronin.EntityDirtyAccount, account.Address.Hex(), account.BlockHash) {
+		return
+	}
+	state.accounts = append(state.accounts, *account)
+}
+
+// state returns the tracking state of a block, or false when the block is not audited
+// because it precedes the first block message or was already finalized.
+func (a *StreamAuditor) state(entity ronin.Entity, number uint64) (*blockState, bool) {
+	if a.started && number < a.first {
+		log.Debugf("Skipping %s message for block %d before the first audited block %d", entity, number, a.first)
+		return nil, false
+	}
+	if a.started && number <= a.finalized {
+		log.Warnf("Late %s message for block %d, already finalized at %d", entity, number, a.finalized)
+		a.late++
+		a.lateBlock = number
+		return nil, false
+	}
+	state, ok := a.blocks[number]
+	if !ok {
+		state = newBlockState(number)
+		a.blocks[number] = state
+	}
+	return state, true
+}
+
+func newBlockState(number uint64) *blockState {
+	return &blockState{
+		number: number,
+		seen:   make(map[ronin.Entity]map[string]common.Hash),
+		last:   make(map[ronin.Entity]uint64),
+	}
+}
+
+func (s *blockState) add(kind, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	log.Warn(message)
+	s.issues = append(s.issues, issue{kind: kind, message: message})
+}
+
+func (s *blockState) has(entity ronin.Entity, key string) bool {
+	_, ok := s.seen[entity][key]
+	return ok
+}
+
+// markSeen records key of a record of the block blockHash and reports false for duplicates.
+// A record of another branch of the block is not a duplicate, reorged txs are published again.
+func (a *StreamAuditor) markSeen(state *blockState, entity ronin.Entity, key string, blockHash common.Hash) bool {
+	seen, ok := state.seen[entity]
+	if !ok {
+		seen = make(map[string]common.Hash)
+		state.seen[entity] = seen
+	}
+	if previous, ok := seen[key]; ok && previous == blockHash {
+		state.add(IssueDuplicate, "duplicate %s %s in block %d", entity, key, state.number)
+		return false
+	}
+	seen[key] = blockHash
+	return true
+}
+
+// replace drops the records and issues of the branch the block is replaced from, the
+// records already received for the new branch hash are kept.
+func (s *blockState) replace(hash common.Hash) {
+	s.issues = nil
+	s.last = make(map[ronin.Entity]uint64)
+	s.seen = map[ronin.Entity]map[string]common.Hash{ronin.EntityBlock: {hash.Hex(): hash}}
+	txs, logs, itxs, accounts := s.txs, s.logs, s.itxs, s.accounts
+	s.txs, s.logs, s.itxs, s.accounts = nil, nil, nil, nil
+	keep := func(entity ronin.Entity, key string, blockHash common.Hash, index uint64, ordered bool) bool {
+		if blockHash != hash {
+			return false
+		}
+		if s.seen[entity] == nil {
+			s.seen[entity] = make(map[string]common.Hash)
+		}
+		s.seen[entity][key] = blockHash
+		if last, ok := s.last[entity]; ordered && (!ok || index > last) {
+			s.last[entity] = index
+		}
+		return true
+	}
+	for _, tx := range txs {
+		if keep(ronin.EntityTransaction, tx.Hash.Hex(), tx.BlockHash, uint64(tx.TransactionIndex), true) {
+			s.txs = append(s.txs, tx)
+		}
+	}
+	for _, l := range logs {
+		if keep(ronin.EntityLog, fmt.Sprint(l.Index), l.BlockHash, uint64(l.Index), true) {
+			s.logs = append(s.logs, l)
+		}
+	}
+	for _, itx := range itxs {
+		if keep(ronin.EntityInternalTransaction, fmt.Sprint(itx.Index), itx.BlockHash, uint64(itx.Index), true) {
+			s.itxs = append(s.itxs, itx)
+		}
+	}
+	for _, account := range accounts {
+		if keep(ronin.EntityDirtyAccount, account.Address.Hex(), account.BlockHash, 0, false) {
+			s.accounts = append(s.accounts, account)
+		}
+	}
+}
+
+func (a *StreamAuditor) checkOrder(state *blockState, entity ronin.Entity, index uint64, id string) {
+	last, ok := state.last[entity]
+	if ok && index < last {
+		state.add(IssueOutOfOrder, "%s %s with index %d arrived after index %d in block %d", entity, id, index, last, state.number)
+		return
+	}
+	state.last[entity] = index
+}
+
+// advance moves the head of the stream and finalizes blocks older than the completion depth.
+func (a *StreamAuditor) advance(number uint64) {
+	if !a.started {
+		a.started = true
+		a.first = number
+		a.highest = number
+		if number > 0 {
+			a.finalized = number - 1
+		}
+		// records of earlier blocks were consumed before the first block message
+		for pending := range a.blocks {
+			if pending < number {
+				delete(a.blocks, pending)
+				a.offsets.release(pending)
+			}
+		}
+		return
+	}
+	if number <= a.highest {
+		return
+	}
+	gapStart := a.highest + 1
+	if number-gapStart > maxGapBlocks {
+		gapStart = number - maxGapBlocks
+	}
+	for missing := gapStart; missing < number; missing++ {
+		if _, ok := a.blocks[missing]; !ok {
+			a.blocks[missing] = newBlockState(missing)
+		}
+	}
+	a.highest = number
+
+	for a.finalized+a.cfg.CompletionDepth < a.highest {
+		a.finalized++
+		if state, ok := a.blocks[a.finalized]; ok {
+			delete(a.blocks, a.finalized)
+			a.finalize(state)
+		}
+	}
+}
+
+// flush finalizes every pending block, used when a finite source is exhausted.
+func (a *StreamAuditor) flush() {
+	numbers := make([]uint64, 0, len(a.blocks))
+	for number := range a.blocks {
+		numbers = append(numbers, number)
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+	for _, number := range numbers {
+		state := a.blocks[number]
+		delete(a.blocks, number)
+		a.finalize(state)
+	}
+}
+
+// finalize records the latency of a complete block and hands it to the verify workers.
+// The state is no longer touched by the consumer loop afterwards.
+func (a *StreamAuditor) finalize(state *blockState) {
+	if a.late > 0 {
+		a.conditions.Breach(IssueLate, fmt.Sprintf("%d explorer messages arrived for already finalized blocks, latest for block %d", a.late, a.lateBlock))
+		a.late = 0
+	} else {
+		a.conditions.Recover(IssueLate, fmt.Sprintf("Explorer messages arrive in time again at block %d", state.number))
+	}
+
+	if block := state.block; block != nil {
+		for i := range state.txs {
+			a.latency.ObserveTransaction(&state.txs[i], block)
+		}
+		for i := range state.logs {
+			a.latency.ObserveLog(&state.logs[i], block)
+		}
+		for i := range state.itxs {
+			a.latency.ObserveInternalTransaction(&state.itxs[i], block)
+		}
+		for i := range state.accounts {
+			a.latency.ObserveDirtyAccount(&state.accounts[i], block)
+		}
+		a.latency.Evaluate(block.Number)
+	}
+
+	a.store.Add(stateRecords(state)...)
+	state.sequence = a.sequence
+	a.sequence++
+	a.verify <- state
+}
+
+func stateRecords(state *blockState) []RecordRef {
+	records := make([]RecordRef, 0, 1+len(state.txs)+len(state.logs)+len(state.itxs))
+	if state.block != nil {
+		records = append(records, blockRef(state.block))
+	}
+	for i := range state.txs {
+		records = append(records, transactionRef(&state.txs[i]))
+	}
+	for i := range state.logs {
+		records = append(records, logRef(&state.logs[i]))
+	}
+	for i := range state.itxs {
+		records = append(records, internalTransactionRef(&state.itxs[i]))
+	}
+	return records
+}
+
+// verifyBlock runs the checks that need every message of the block and verifies the records
+// against the chain.
+func (a *StreamAuditor) verifyBlock(ctx context.Context, state *blockState) {
+	block := state.block
+	if block == nil {
+		state.add(IssueMissingBlock, "missing block message for block %d", state.number)
+	} else {
+		for _, mismatch := range ronin.CheckTimestamps([]ronin.Block{*block}, state.txs, state.logs, state.itxs) {
+			state.add(IssueTimestamp, "%s", mismatch)
+		}
+		for _, hash := range block.Transactions {
+			if !state.has(ronin.EntityTransaction, hash.Hex()) {
+				state.add(IssueMissingRecord, "missing tx %s of block %d", hash.Hex(), state.number)
+			}
+		}
+		for i := range state.txs {
+			errs, unverified := ronin.VerifyInternalTransactions(&state.txs[i], state.itxs)
+			for _, err := range errs {
+				state.add(IssueContractAddress, "%v", err)
+			}
+			// a factory none of the known ABIs covers, its deployments stay unchecked until it is added
+			for _, itx := range unverified {
+				state.add(IssueUnverifiedCreate2, "cannot recover the CREATE2 salt of internal tx %d of %s, factory %s created %s", itx.Index, itx.TransactionHash.Hex(), itx.From.Hex(), itx.To.Hex())
+			}
+		}
+	}
+	if last, ok := state.last[ronin.EntityInternalTransaction]; ok {
+		for index := uint64(0); index < last; index++ {
+			if !state.has(ronin.EntityInternalTransaction, fmt.Sprint(index)) {
+				state.add(IssueMissingRecord, "missing internal tx %d of block %d", index, state.number)
+			}
+		}
+	}
+
+	a.verifyOnChain(ctx, state)
+}
+
+func (a *StreamAuditor) verifyOnChain(ctx context.Context, state *blockState) {
+	canonical, err := a.client.GetBlockByNumber(ctx, state.number)
+	if err != nil {
+		state.add(IssueVerificationFailed, "cannot fetch canonical block %d: %v", state.number, err)
+		return
+	}
+	if state.block != nil && state.block.Hash != canonical.Hash {
+		state.add(IssueNotCanonical, "block %d hash %s is not canonical, chain has %s", state.number, state.block.Hash.Hex(), canonical.Hash.Hex())
+	}
+
+	txs := make([]*ronin.Transaction, 0, len(state.txs))
+	for i := range state.txs {
+		if a.isCanonical(state, ronin.EntityTransaction, state.txs[i].Hash.Hex(), state.txs[i].BlockHash, canonical.Hash) {
+			txs = append(txs, &state.txs[i])
+		}
+	}
+	for _, itx := range state.itxs {
+		a.isCanonical(state, ronin.EntityInternalTransaction, fmt.Sprintf("%s#%d", itx.TransactionHash.Hex(), itx.Index), itx.BlockHash, canonical.Hash)
+	}
+	logs := make([]*ronin.Log, 0, len(state.logs))
+	for i := range state.logs {
+		if a.isCanonical(state, ronin.EntityLog, fmt.Sprintf("%s#%d", state.logs[i].TxHash.Hex(), state.logs[i].Index), state.logs[i].BlockHash, canonical.Hash) {
+			logs = append(logs, &state.logs[i])
+		}
+	}
+	accounts := make([]*ronin.DirtyAccount, 0, len(state.accounts))
+	for i := range state.accounts {
+		account := &state.accounts[i]
+		if a.isCanonical(state, ronin.EntityDirtyAccount, account.Address.Hex(), account.BlockHash, canonical.Hash) && !account.Deleted && !account.Suicided {
+			accounts = append(accounts, account)
+		}
+	}
+
+	a.verifyTransactions(ctx, state, txs)
+	a.verifyLogs(ctx, state, canonical.Hash, logs)
+	a.verifyAccounts(ctx, state, accounts)
+}
+
+func (a *StreamAuditor) isCanonical(state *blockState, entity ronin.Entity, id string, blockHash, canonical common.Hash) bool {
+	if blockHash != canonical {
+		state.add(IssueNotCanonical, "%s %s references block hash %s, canonical block %d is %s", entity, id, blockHash.Hex(), state.number, canonical.Hex())
+		return false
+	}
+	return true
+}
+
+func (a *StreamAuditor) verifyTransactions(ctx context.Context, state *blockState, txs []*ronin.Transaction) {
+	for _, chunk := range chunks(len(txs), a.cfg.VerifyBatchSize) {
+		part := txs[chunk[0]:chunk[1]]
+		hashes := make([]common.Hash, len(part))
+		for i, tx := range part {
+			hashes[i] = tx.Hash
+		}
+		receipts, err := a.client.BatchGetTransactionReceipt(ctx, hashes)
+		if err != nil {
+			state.add(IssueVerificationFailed, "cannot fetch %d receipts of block %d: %v", len(hashes), state.number, err)
+			continue
+		}
+		chainTxs, err := a.client.BatchGetTransactionByHash(ctx, hashes)
+		if err != nil {
+			state.add(IssueVerificationFailed, "cannot fetch %d txs of block %d: %v", len(hashes), state.number, err)
+			continue
+		}
+
+		for i, tx := range part {
+			receipt, chainTx := receipts[i], chainTxs[i]
+			if receipt == nil || chainTx == nil {
+				state.add(IssueTransactionDiff, "tx %s of block %d does not exist on chain", tx.Hash.Hex(), state.number)
+				continue
+			}
+			diffs := make([]string, 0)
+			diffs = appendDiff(diffs, "blockHash", tx.BlockHash.Hex(), receipt.BlockHash.Hex())
+			diffs = appendDiff(diffs, "transactionIndex", uint64(tx.TransactionIndex), uint64(receipt.TransactionIndex))
+			diffs = appendDiff(diffs, "status", tx.Status, uint64(receipt.Status))
+			diffs = appendDiff(diffs, "gasUsed", tx.GasUsed, uint64(receipt.GasUsed))
+			diffs = appendDiff(diffs, "cumulativeGasUsed", tx.CumulativeGasUsed, uint64(receipt.CumulativeGasUsed))
+			if receipt.ContractAddress != nil {
+				diffs = appendDiff(diffs, "contractAddress", tx.ContractAddress.Hex(), receipt.ContractAddress.Hex())
+			}
+			diffs = appendDiff(diffs, "from", tx.From.Hex(), chainTx.From.Hex())
+			diffs = appendDiff(diffs, "nonce", uint64(tx.Nonce), uint64(chainTx.Nonce))
+			diffs = appendDiff(diffs, "to", addressString(tx.To), addressString(chainTx.To))
+			diffs = appendDiff(diffs, "value", bigString(tx.Value), bigString(chainTx.Value))
+			diffs = appendDiff(diffs, "input", strings.ToLower(tx.Input), chainTx.Input.String())
+			if len(diffs) > 0 {
+				state.add(IssueTransactionDiff, "tx %s differs from chain: %s", tx.Hash.Hex(), strings.Join(diffs, ", "))
+			}
+		}
+	}
+}
+
+func (a *StreamAuditor) verifyLogs(ctx context.Context, state *blockState, canonical common.Hash, logs []*ronin.Log) {
+	if state.block == nil && len(logs) == 0 {
+		return
+	}
+	chainLogs, err := a.client.GetLogsByBlockHash(ctx, canonical)
+	if err != nil {
+		state.add(IssueVerificationFailed, "cannot fetch logs of block %d: %v", state.number, err)
+		return
+	}
+
+	byIndex := make(map[uint]rpc.LogResponse, len(chainLogs))
+	for _, chainLog := range chainLogs {
+		byIndex[uint(chainLog.LogIndex)] = chainLog
+		if !state.has(ronin.EntityLog, fmt.Sprint(uint(chainLog.LogIndex))) {
+			state.add(IssueMissingRecord, "missing log %s#%d of block %d", chainLog.TransactionHash.Hex(), chainLog.LogIndex, state.number)
+		}
+	}
+	for _, l := range logs {
+		id := fmt.Sprintf("%s#%d", l.TxHash.Hex(), l.Index)
+		chainLog, ok := byIndex[l.Index]
+		if !ok {
+			state.add(IssueLogDiff, "log %s does not exist on chain", id)
+			continue
+		}
+		diffs := make([]string, 0)
+		diffs = appendDiff(diffs, "address", l.Address.Hex(), chainLog.Address.Hex())
+		diffs = appendDiff(diffs, "transactionHash", l.TxHash.Hex(), chainLog.TransactionHash.Hex())
+		diffs = appendDiff(diffs, "transactionIndex", l.TxIndex, uint(chainLog.TransactionIndex))
+		diffs = appendDiff(diffs, "topics", fmt.Sprint(l.Topics), fmt.Sprint(chainLog.Topics))
+		diffs = appendDiff(diffs, "data", l.Data.String(), chainLog.Data.String())
+		diffs = appendDiff(diffs, "removed", l.Removed, chainLog.Removed)
+		if len(diffs) > 0 {
+			state.add(IssueLogDiff, "log %s differs from chain: %s", id, strings.Join(diffs, ", "))
+		}
+	}
+}
+
+func (a *StreamAuditor) verifyAccounts(ctx context.Context, state *blockState, accounts []*ronin.DirtyAccount) {
+	for _, chunk := range chunks(len(accounts), a.cfg.VerifyBatchSize) {
+		part := accounts[chunk[0]:chunk[1]]
+		addresses := make([]common.Address, len(part))
+		for i, account := range part {
+			addresses[i] = account.Address
+		}
+		balances, err := a.client.BatchGetBalance(ctx, addresses, state.number)
+		if err != nil {
+			state.add(IssueVerificationFailed, "cannot fetch %d balances at block %d: %v", len(addresses), state.number, err)
+			continue
+		}
+		nonces, err := a.client.BatchGetTransactionCount(ctx, addresses, state.number)
+		if err != nil {
+			state.add(IssueVerificationFailed, "cannot fetch %d nonces at block %d: %v", len(addresses), state.number, err)
+			continue
+		}
+
+		for i, account := range part {
+			diffs := make([]string, 0)
+			diffs = appendDiff(diffs, "balance", bigString(account.Balance), bigString(balances[i]))
+			diffs = appendDiff(diffs, "nonce", account.Nonce, uint64(nonces[i]))
+			if len(diffs) > 0 {
+				state.add(IssueAccountDiff, "dirty account %s at block %d differs from chain: %s", account.Address.Hex(), state.number, strings.Join(diffs, ", "))
+			}
+		}
+	}
+}
+
+// reportInOrder reports the verified blocks in the order they were finalized, the workers
+// finish them in any order. The messages of a reported block are committed to source.
+func (a *StreamAuditor) reportInOrder(ctx context.Context, source MessageSource) {
+	pending := make(map[uint64]*blockState)
+	next := uint64(0)
+	for state := range a.reports {
+		pending[state.sequence] = state
+		for state, ok := pending[next]; ok; state, ok = pending[next] {
+			delete(pending, next)
+			next++
+			if ctx.Err() != nil {
+				// the failed fetches are not issues of the explorer
+				log.Infof("Verification of block %d cut short by shutdown", state.number)
+				continue
+			}
+			a.report(state)
+			a.offsets.release(state.number)
+		}
+		if messages := a.offsets.committable(); len(messages) > 0 {
+			if err := source.Commit(ctx, messages...); err != nil {
+				log.Errorf("Cannot commit the offsets of reported blocks: %v", err)
+			}
+		}
+	}
+}
+
+// report alerts every issue kind that starts with this block. A kind recovers once none of
+// the IssueWindow blocks after its last issue had it, so sporadic issues do not flap.
+func (a *StreamAuditor) report(state *blockState) {
+	byKind := make(map[string][]string)
+	for _, issue := range state.issues {
+		byKind[issue.kind] = append(byKind[issue.kind], issue.message)
+	}
+	for _, kind := range issueKinds {
+		messages, ok := byKind[kind]
+		if !ok {
+			if last, seen := a.lastIssue[kind]; seen && state.number >= last+a.cfg.IssueWindow {
+				delete(a.lastIssue, kind)
+				a.conditions.Recover(kind, fmt.Sprintf("Explorer %s issues resolved, none in blocks %d-%d", kind, last+1, state.number))
+			}
+			continue
+		}
+		a.lastIssue[kind] = state.number
+		shown := messages
+		if len(shown) > maxAlertedIssue {
+			shown = shown[:maxAlertedIssue]
+		}
+		a.conditions.Breach(kind, fmt.Sprintf("Explorer %s issues from block %d (%d in block): %s", kind, state.number, len(messages), strings.Join(shown, "; ")))
+	}
+}
+
+func (a *StreamAuditor) logLatencyReport() {
+	if !a.started {
+		return
+	}
+	from := uint64(0)
+	if a.cfg.LatencyRetention > 0 && a.highest > uint64(a.cfg.LatencyRetention) {
+		from = a.highest - uint64(a.cfg.LatencyRetention)
+	}
+	log.Info(a.latency.Report(from, a.highest).String())
+	if a.client.Cache != nil {
+		log.Infof("Verify RPC cache %s", a.client.Cache.Stats())
+	}
+}
+
+// chunks splits n items into [start, end) ranges of at most size items.
+func chunks(n, size int) [][2]int {
+	if size < 1 {
+		size = n
+	}
+	ranges := make([][2]int, 0)
+	for start := 0; start < n; start += size {
+		end := start + size
+		if end > n {
+			end = n
+		}
+		ranges = append(ranges, [2]int{start, end})
+	}
+	return ranges
+}
+
+func appendDiff[T comparable](diffs []string, field string, stored, chain T) []string {
+	if stored == chain {
+		return diffs
+	}
+	return append(diffs, fmt.Sprintf("%s %v != %v", field, stored, chain))
+}
+
+func addressString(address *common.Address) string {
+	if address == nil {
+		return ""
+	}
+	return address.Hex()
+}
+
+func bigString(value *hexutil.Big) string {
+	if value == nil {
+		return "0"
+	}
+	return value.ToInt().String()
+}
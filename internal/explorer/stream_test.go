@@ -0,0 +1,244 @@
+package explorer
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"go-node-audit/config"
+	"go-node-audit/pkg/ronin"
+	"go-node-audit/pkg/rpc"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type sliceSource struct {
+	messages  []*Message
+	committed []*Message
+}
+
+func (s *sliceSource) Next(ctx context.Context) (*Message, error) {
+	if len(s.messages) == 0 {
+		return nil, io.EOF
+	}
+	message := s.messages[0]
+	s.messages = s.messages[1:]
+	return message, nil
+}
+
+func (s *sliceSource) Commit(ctx context.Context, messages ...*Message) error {
+	s.committed = append(s.committed, messages...)
+	return nil
+}
+
+func (s *sliceSource) Close() error {
+	return nil
+}
+
+func testExplorerConfig() config.Explorer {
+	return config.Explorer{
+		VerifyWorkers:     1,
+		VerifyBatchSize:   10,
+		BlockTopic:        "blocks",
+		TransactionTopic:  "transactions",
+		LogTopic:          "logs",
+		InternalTxTopic:   "internal_transactions",
+		DirtyAccountTopic: "dirty_accounts",
+		CompletionDepth:   2,
+	}
+}
+
+func message(t *testing.T, topic string, value interface{}) *Message {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Message{Topic: topic, Value: encoded}
+}
+
+// unavailableNode answers every RPC call with 503, so every chain verification fails.
+func unavailableNode(t *testing.T) *rpc.JsonRPCClient {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+	return rpc.NewRPCClient(rpc.JsonRpcUrl(server.URL))
+}
+
+type recorder struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (r *recorder) notify(message string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, message)
+}
+
+func (r *recorder) count(substr string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, message := range r.messages {
+		if strings.Contains(message, substr) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestStreamDropsRecordsBeforeFirstBlock(t *testing.T) {
+	auditor := NewStreamAuditor(testExplorerConfig(), unavailableNode(t), func(string) {})
+	auditor.handle(message(t, "transactions", ronin.Transaction{BlockNumber: 5}))
+	auditor.handle(message(t, "blocks", ronin.Block{Number: 7}))
+
+	if _, ok := auditor.blocks[5]; ok {
+		t.Fatal("state of block 5 kept after stream started at block 7")
+	}
+	if _, ok := auditor.blocks[7]; !ok {
+		t.Fatal("state of block 7 missing")
+	}
+}
+
+func TestStreamAlertsOncePerIssueKind(t *testing.T) {
+	alerts := &recorder{}
+	auditor := NewStreamAuditor(testExplorerConfig(), unavailableNode(t), alerts.notify)
+
+	source := &sliceSource{}
+	for number := uint64(1); number <= 8; number++ {
+		// an empty logs bloom fails the header hash check of every block
+		source.messages = append(source.messages, message(t, "blocks", ronin.Block{Number: number, Timestamp: 1}))
+	}
+	// arrives after block 1 was finalized
+	source.messages = append(source.messages, message(t, "logs", ronin.Log{BlockNumber: 1}))
+
+	if err := auditor.Run(context.Background(), source); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		substr string
+		want   int
+	}{
+		{"Explorer " + IssueHeaderHash + " issues from", 1},
+		{"Explorer " + IssueVerificationFailed + " issues from", 1},
+		{"Explorer " + IssueTimestamp + " issues from", 1},
+		{"arrived for already finalized blocks", 1},
+	}
+	for _, tt := range tests {
+		if got := alerts.count(tt.substr); got != tt.want {
+			t.Errorf("%q alerted %d times, want %d: %v", tt.substr, got, tt.want, alerts.messages)
+		}
+	}
+	if got := alerts.count("has 1 issues"); got != 0 {
+		t.Errorf("late message reported on a block: %v", alerts.messages)
+	}
+}
+
+func TestStreamReplacesReorgedBlock(t *testing.T) {
+	auditor := NewStreamAuditor(testExplorerConfig(), unavailableNode(t), func(string) {})
+	old, replacement := common.HexToHash("0x0a"), common.HexToHash("0x0b")
+	shared, dropped := common.HexToHash("0x01"), common.HexToHash("0x02")
+	auditor.handle(message(t, "blocks", ronin.Block{Number: 5, Hash: old}))
+	auditor.handle(message(t, "transactions", ronin.Transaction{BlockNumber: 5, BlockHash: old, Hash: shared}))
+	auditor.handle(message(t, "transactions", ronin.Transaction{BlockNumber: 5, BlockHash: old, Hash: dropped, TransactionIndex: 1}))
+	// the new branch includes the shared tx again, its tx arrives before the block
+	auditor.handle(message(t, "transactions", ronin.Transaction{BlockNumber: 5, BlockHash: replacement, Hash: shared}))
+	auditor.handle(message(t, "blocks", ronin.Block{Number: 5, Hash: replacement}))
+
+	state := auditor.blocks[5]
+	if state.block.Hash != replacement {
+		t.Fatalf("block hash %s, want the replacement", state.block.Hash.Hex())
+	}
+	if len(state.txs) != 1 || state.txs[0].BlockHash != replacement {
+		t.Fatalf("txs %v, want the shared tx of the new branch only", state.txs)
+	}
+	for _, issue := range state.issues {
+		if issue.kind == IssueDuplicate {
+			t.Fatalf("reorg reported as duplicate: %s", issue.message)
+		}
+	}
+
+	auditor.handle(message(t, "blocks", ronin.Block{Number: 5, Hash: replacement}))
+	if n := len(state.issues); n == 0 || state.issues[n-1].kind != IssueDuplicate {
+		t.Fatalf("identical block not reported as duplicate: %v", state.issues)
+	}
+}
+
+func TestStreamReportsInFinalizeOrder(t *testing.T) {
+	alerts := &recorder{}
+	cfg := testExplorerConfig()
+	cfg.IssueWindow = 3
+	auditor := NewStreamAuditor(cfg, unavailableNode(t), alerts.notify)
+	auditor.reports = make(chan *blockState, 8)
+
+	// block 11 has an issue, blocks 10 and 12 are clean, the workers finish them out of order
+	states := make([]*blockState, 6)
+	for i := range states {
+		states[i] = newBlockState(uint64(10 + i))
+		states[i].sequence = uint64(i)
+	}
+	states[1].add(IssueLogDiff, "log differs")
+	for _, i := range []int{2, 1, 0, 5, 4, 3} {
+		auditor.reports <- states[i]
+	}
+	close(auditor.reports)
+	auditor.reportInOrder(context.Background(), &sliceSource{})
+
+	want := []string{"Explorer " + IssueLogDiff + " issues from block 11", "Explorer " + IssueLogDiff + " issues resolved, none in blocks 12-14"}
+	if len(alerts.messages) != len(want) {
+		t.Fatalf("alerts %v, want %v", alerts.messages, want)
+	}
+	for i, prefix := range want {
+		if !strings.HasPrefix(alerts.messages[i], prefix) {
+			t.Errorf("alert %d = %q, want %q", i, alerts.messages[i], prefix)
+		}
+	}
+}
+
+func TestStreamSporadicIssuesDoNotFlap(t *testing.T) {
+	alerts := &recorder{}
+	cfg := testExplorerConfig()
+	cfg.IssueWindow = 5
+	auditor := NewStreamAuditor(cfg, unavailableNode(t), alerts.notify)
+
+	for number := uint64(1); number <= 12; number++ {
+		state := newBlockState(number)
+		if number%3 == 0 {
+			state.add(IssueTransactionDiff, "tx differs")
+		}
+		auditor.report(state)
+	}
+	if len(alerts.messages) != 1 {
+		t.Fatalf("alerts %v, want a single breach", alerts.messages)
+	}
+}
+
+func TestStreamCommitsReportedBlocks(t *testing.T) {
+	auditor := NewStreamAuditor(testExplorerConfig(), unavailableNode(t), func(string) {})
+	source := &sliceSource{}
+	for number := uint64(1); number <= 4; number++ {
+		block := message(t, "blocks", ronin.Block{Number: number})
+		block.Offset = int64(2 * number)
+		tx := message(t, "transactions", ronin.Transaction{BlockNumber: number})
+		tx.Offset = int64(2*number + 1)
+		source.messages = append(source.messages, block, tx)
+	}
+
+	if err := auditor.Run(context.Background(), source); err != nil {
+		t.Fatal(err)
+	}
+	committed := make(map[string]int64)
+	for _, message := range source.committed {
+		committed[message.Topic] = message.Offset
+	}
+	if committed["blocks"] != 8 || committed["transactions"] != 9 {
+		t.Fatalf("committed %v, want every consumed offset", committed)
+	}
+}
@@ -0,0 +1,115 @@
+package gateway
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"go-node-audit/internal/audit"
+	"go-node-audit/pkg/rpc"
+
+	golog "github.com/ipfs/go-log"
+)
+
+var log = golog.Logger("Gateway")
+
+// Balancer routes requests to the nodes the audit does not alert on, least lagging
+// and fastest first. A node failing a request is tried last for a cooldown, the audit
+// takes a while to notice.
+type Balancer struct {
+	health   func() map[string]audit.NodeHealth
+	backends []*rpc.Backend
+	cooldown time.Duration
+	// node name to class, and the class the requests a node of a class asks to forward go to
+	Classes        map[string]string
+	ForwardClasses map[string]string
+	// routes requests to a class of nodes, any node serves every request when nil
+	Policy *Policy
+
+	mu     sync.Mutex
+	failed map[string]time.Time
+	now    func() time.Time
+}
+
+func NewBalancer(health func() map[string]audit.NodeHealth, backends []*rpc.Backend, cooldown time.Duration) *Balancer {
+	return &Balancer{
+		health:   health,
+		backends: backends,
+		cooldown: cooldown,
+		failed:   make(map[string]time.Time),
+		now:      time.Now,
+	}
+}
+
+// Backends returns the healthy backends of the class the policy routes request to, best first.
+// Alerted nodes and nodes without a head yet are never returned.
+func (b *Balancer) Backends(request rpc.ServerRequest) []*rpc.Backend {
+	view := b.health()
+	now := b.now()
+	class := ""
+	if b.Policy != nil {
+		head := uint64(0)
+		for _, health := range view {
+			if health.Height > head {
+				head = health.Height
+			}
+		}
+		class = b.Policy.Class(request, head)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	healthy := make([]*rpc.Backend, 0, len(b.backends))
+	cooling := make([]*rpc.Backend, 0)
+	for _, backend := range b.backends {
+		health, ok := view[backend.Name]
+		if !ok || !health.Healthy() {
+			continue
+		}
+		if class != "" && b.Classes[backend.Name] != class {
+			continue
+		}
+		if failed, ok := b.failed[backend.Name]; ok && now.Sub(failed) < b.cooldown {
+			cooling = append(cooling, backend)
+			continue
+		}
+		healthy = append(healthy, backend)
+	}
+	rank := func(backends []*rpc.Backend) {
+		sort.SliceStable(backends, func(i, j int) bool {
+			a, b := view[backends[i].Name], view[backends[j].Name]
+			if a.Lag != b.Lag {
+				return a.Lag < b.Lag
+			}
+			return a.Latency < b.Latency
+		})
+	}
+	rank(healthy)
+	rank(cooling)
+	return append(healthy, cooling...)
+}
+
+func (b *Balancer) Failed(backend *rpc.Backend, err error) {
+	now := b.now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if failed, ok := b.failed[backend.Name]; !ok || now.Sub(failed) >= b.cooldown {
+		log.Warnf("%s node failed a request, trying it last for %s: %v", backend.Name, b.cooldown, err)
+	}
+	b.failed[backend.Name] = now
+}
+
+// ForwardTargets returns the healthy nodes of the forward class of the class of backend.
+func (b *Balancer) ForwardTargets(backend *rpc.Backend, request rpc.ServerRequest) []*rpc.Backend {
+	class, ok := b.ForwardClasses[b.Classes[backend.Name]]
+	if !ok {
+		return nil
+	}
+	targets := make([]*rpc.Backend, 0)
+	for _, target := range b.Backends(request) {
+		if target.Name != backend.Name && b.Classes[target.Name] == class {
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
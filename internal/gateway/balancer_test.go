@@ -0,0 +1,99 @@
+package gateway
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go-node-audit/config"
+	"go-node-audit/internal/audit"
+	"go-node-audit/pkg/rpc"
+)
+
+func names(backends []*rpc.Backend) []string {
+	names := make([]string, len(backends))
+	for i, backend := range backends {
+		names[i] = backend.Name
+	}
+	return names
+}
+
+func TestBalancerRanksHealthyNodes(t *testing.T) {
+	view := map[string]audit.NodeHealth{
+		"infinity":    {Node: "infinity", Lag: 1, Latency: 50 * time.Millisecond},
+		"infinity-nv": {Node: "infinity-nv", Lag: 0, Latency: 300 * time.Millisecond},
+		"eternity":    {Node: "eternity", Lag: 0, Latency: 80 * time.Millisecond},
+		"catalyst":    {Node: "catalyst", Alerts: []string{"stale:catalyst"}},
+	}
+	backends := []*rpc.Backend{{Name: "infinity"}, {Name: "infinity-nv"}, {Name: "eternity"}, {Name: "catalyst"}, {Name: "unknown"}}
+	balancer := NewBalancer(func() map[string]audit.NodeHealth { return view }, backends, time.Minute)
+	now := time.Now()
+	balancer.now = func() time.Time { return now }
+
+	want := []string{"eternity", "infinity-nv", "infinity"}
+	if got := names(balancer.Backends(rpc.ServerRequest{})); !equal(got, want) {
+		t.Fatalf("backends %v, want %v", got, want)
+	}
+
+	balancer.Failed(backends[2], errors.New("connection refused"))
+	want = []string{"infinity-nv", "infinity", "eternity"}
+	if got := names(balancer.Backends(rpc.ServerRequest{})); !equal(got, want) {
+		t.Fatalf("backends after a failure %v, want %v", got, want)
+	}
+
+	now = now.Add(time.Minute)
+	want = []string{"eternity", "infinity-nv", "infinity"}
+	if got := names(balancer.Backends(rpc.ServerRequest{})); !equal(got, want) {
+		t.Fatalf("backends after the cooldown %v, want %v", got, want)
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestBalancerForwardTargets(t *testing.T) {
+	view := map[string]audit.NodeHealth{
+		"infinity":    {Node: "infinity"},
+		"eternity":    {Node: "eternity"},
+		"infinity-nv": {Node: "infinity-nv"},
+		"catalyst":    {Node: "catalyst", Alerts: []string{"lag:catalyst"}},
+	}
+	backends := []*rpc.Backend{{Name: "infinity"}, {Name: "eternity"}, {Name: "infinity-nv"}, {Name: "catalyst"}}
+	balancer := NewBalancer(func() map[string]audit.NodeHealth { return view }, backends, time.Minute)
+	balancer.Classes = map[string]string{"infinity": "full", "eternity": "full", "infinity-nv": "archive", "catalyst": "archive"}
+	balancer.ForwardClasses = map[string]string{"full": "archive"}
+
+	if got := names(balancer.ForwardTargets(backends[0], rpc.ServerRequest{})); !equal(got, []string{"infinity-nv"}) {
+		t.Fatalf("forward targets %v, want the healthy archive node", got)
+	}
+	if got := balancer.ForwardTargets(backends[2], rpc.ServerRequest{}); len(got) != 0 {
+		t.Fatalf("archive nodes forward to %v, want nowhere", names(got))
+	}
+}
+
+func TestBalancerRoutesToPolicyClass(t *testing.T) {
+	view := map[string]audit.NodeHealth{
+		"infinity":    {Node: "infinity", Height: 10000},
+		"infinity-nv": {Node: "infinity-nv", Height: 10000, Latency: time.Second},
+	}
+	backends := []*rpc.Backend{{Name: "infinity"}, {Name: "infinity-nv"}}
+	balancer := NewBalancer(func() map[string]audit.NodeHealth { return view }, backends, time.Minute)
+	balancer.Classes = map[string]string{"infinity": "full", "infinity-nv": "archive"}
+	balancer.Policy = NewPolicy(config.Gateway{HistoryClass: "archive", HistoryDepth: 128})
+
+	if got := names(balancer.Backends(request(rpc.ETHGetBalance, `["0x00", "0x10"]`))); !equal(got, []string{"infinity-nv"}) {
+		t.Fatalf("historical read routed to %v, want the archive node", got)
+	}
+	if got := names(balancer.Backends(request(rpc.ETHGetBalance, `["0x00", "latest"]`))); !equal(got, []string{"infinity", "infinity-nv"}) {
+		t.Fatalf("recent read routed to %v, want every node", got)
+	}
+}
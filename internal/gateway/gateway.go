@@ -0,0 +1,32 @@
+package gateway
+
+import (
+	"fmt"
+
+	"go-node-audit/config"
+	"go-node-audit/internal/audit"
+	"go-node-audit/pkg/rpc"
+)
+
+// Backends returns the configured nodes of names, in their own clients so gateway traffic
+// does not share connections with the audit probes.
+func Backends(cfg *config.Config, names []string) ([]*rpc.Backend, error) {
+	reference, nodes := audit.Nodes(cfg)
+	byName := make(map[string]*audit.Node)
+	for _, node := range append([]*audit.Node{reference}, nodes...) {
+		byName[node.Name] = node
+	}
+	backends := make([]*rpc.Backend, 0, len(names))
+	for _, name := range names {
+		node, ok := byName[name]
+		if !ok {
+			log.Warnf("Node %s has no RPC endpoint configured", name)
+			continue
+		}
+		backends = append(backends, &rpc.Backend{Name: node.Name, Client: node.Client})
+	}
+	if len(backends) == 0 {
+		return nil, fmt.Errorf("none of the nodes %v is configured", names)
+	}
+	return backends, nil
+}
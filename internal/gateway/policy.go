@@ -0,0 +1,184 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-node-audit/config"
+	"go-node-audit/internal/ratelimit"
+	"go-node-audit/pkg/rpc"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+const apiKeyHeader = "X-Api-Key"
+
+// index of the block parameter of the methods reading state or blocks at a height
+var blockParams = map[string]int{
+	rpc.ETHGetBalance:                          1,
+	rpc.ETHGetTransactionCount:                 1,
+	rpc.ETHGetCode:                             1,
+	rpc.ETHCall:                                1,
+	rpc.ETHGetStorageAt:                        2,
+	rpc.ETHGetProof:                            2,
+	rpc.ETHGetBlockByNumber:                    0,
+	rpc.ETHGetBlockTransactionCountByNumber:    0,
+	rpc.ETHGetTransactionByBlockNumberAndIndex: 0,
+}
+
+// Policy is the access policy and the routing of the gateway. Method patterns are method
+// names, or prefixes ending with *. The longest pattern matching a method wins.
+type Policy struct {
+	allowed      []string
+	denied       []string
+	routes       map[string]string
+	historyClass string
+	historyDepth uint64
+	timeout      time.Duration
+	timeouts     map[string]time.Duration
+
+	requireKey bool
+	keys       map[string]*ratelimit.Bucket
+	anonymous  *ratelimit.Bucket
+}
+
+func NewPolicy(cfg config.Gateway) *Policy {
+	policy := &Policy{
+		allowed:      cfg.AllowedMethods,
+		denied:       cfg.DeniedMethods,
+		routes:       cfg.MethodRoutes,
+		historyClass: cfg.HistoryClass,
+		historyDepth: cfg.HistoryDepth,
+		timeout:      cfg.Timeout,
+		timeouts:     cfg.MethodTimeouts,
+		requireKey:   cfg.RequireApiKey,
+		keys:         make(map[string]*ratelimit.Bucket, len(cfg.ApiKeyRates)),
+	}
+	for key, rate := range cfg.ApiKeyRates {
+		policy.keys[key] = ratelimit.NewBucket(rate, int(rate))
+	}
+	if cfg.AnonymousRate > 0 {
+		policy.anonymous = ratelimit.NewBucket(cfg.AnonymousRate, int(cfg.AnonymousRate))
+	}
+	return policy
+}
+
+// longestMatch returns the longest of patterns matching method, and false when none does.
+func longestMatch(patterns []string, method string) (string, bool) {
+	best, found := "", false
+	for _, pattern := range patterns {
+		prefix := strings.TrimSuffix(pattern, "*")
+		matched := pattern == method || (prefix != pattern && strings.HasPrefix(method, prefix))
+		if matched && (!found || len(pattern) > len(best)) {
+			best, found = pattern, true
+		}
+	}
+	return best, found
+}
+
+func keys[V any](m map[string]V) []string {
+	patterns := make([]string, 0, len(m))
+	for pattern := range m {
+		patterns = append(patterns, pattern)
+	}
+	return patterns
+}
+
+// Allowed reports whether method may be called. Methods matching no pattern are allowed
+// unless there is an allowlist.
+func (p *Policy) Allowed(method string) bool {
+	allow, allowed := longestMatch(p.allowed, method)
+	deny, denied := longestMatch(p.denied, method)
+	switch {
+	case allowed && denied:
+		return len(allow) > len(deny)
+	case allowed || denied:
+		return allowed
+	}
+	return len(p.allowed) == 0
+}
+
+// Check refuses methods that are not allowed and clients over their rate. The API key is
+// read from the X-Api-Key header or the apikey query parameter.
+func (p *Policy) Check(r *http.Request, request rpc.ServerRequest) *rpc.ErrorObject {
+	if !p.Allowed(request.Method) {
+		return &rpc.ErrorObject{Code: rpc.MethodNotFound, Message: fmt.Sprintf("the method %s does not exist/is not available", request.Method)}
+	}
+
+	key := r.Header.Get(apiKeyHeader)
+	if key == "" {
+		key = r.URL.Query().Get("apikey")
+	}
+	bucket := p.anonymous
+	if key != "" {
+		var ok bool
+		if bucket, ok = p.keys[key]; !ok {
+			return &rpc.ErrorObject{Code: rpc.InvalidRequest, Message: "unknown API key"}
+		}
+	} else if p.requireKey {
+		return &rpc.ErrorObject{Code: rpc.InvalidRequest, Message: "API key required"}
+	}
+	if bucket != nil && !bucket.Allow() {
+		return &rpc.ErrorObject{Code: rpc.LimitExceeded, Message: "request rate limit exceeded"}
+	}
+	return nil
+}
+
+func (p *Policy) Timeout(request rpc.ServerRequest) time.Duration {
+	if pattern, ok := longestMatch(keys(p.timeouts), request.Method); ok {
+		return p.timeouts[pattern]
+	}
+	return p.timeout
+}
+
+// Class returns the class of the nodes request must go to, empty when any node serves it.
+// Requests reading a block more than the history depth below head go to the history class.
+func (p *Policy) Class(request rpc.ServerRequest, head uint64) string {
+	if pattern, ok := longestMatch(keys(p.routes), request.Method); ok {
+		return p.routes[pattern]
+	}
+	if p.historyClass == "" || head == 0 {
+		return ""
+	}
+	if number, ok := blockNumber(request); ok && number+p.historyDepth < head {
+		return p.historyClass
+	}
+	return ""
+}
+
+// blockNumber returns the height request reads at, false for tags following the head
+// and block hashes.
+func blockNumber(request rpc.ServerRequest) (uint64, bool) {
+	if request.Params == nil {
+		return 0, false
+	}
+	var params []json.RawMessage
+	if err := json.Unmarshal(*request.Params, &params); err != nil {
+		return 0, false
+	}
+	var block json.RawMessage
+	if request.Method == rpc.ETHGetLogs {
+		var filter struct {
+			FromBlock json.RawMessage `json:"fromBlock"`
+		}
+		if len(params) == 0 || json.Unmarshal(params[0], &filter) != nil {
+			return 0, false
+		}
+		block = filter.FromBlock
+	} else if index, ok := blockParams[request.Method]; ok && index < len(params) {
+		block = params[index]
+	}
+
+	var tag string
+	if json.Unmarshal(block, &tag) != nil {
+		return 0, false
+	}
+	if tag == "earliest" {
+		return 0, true
+	}
+	number, err := hexutil.DecodeUint64(tag)
+	return number, err == nil
+}
@@ -0,0 +1,101 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-node-audit/config"
+	"go-node-audit/pkg/rpc"
+)
+
+func request(method, params string) rpc.ServerRequest {
+	raw := json.RawMessage(params)
+	return rpc.ServerRequest{Version: rpc.JSONRPCVersion, Method: method, Params: &raw}
+}
+
+func TestPolicyAllowed(t *testing.T) {
+	tests := []struct {
+		allowed []string
+		denied  []string
+		method  string
+		want    bool
+	}{
+		{nil, []string{"debug_*", "admin_*"}, "eth_call", true},
+		{nil, []string{"debug_*", "admin_*"}, "admin_addPeer", false},
+		{[]string{"debug_traceInternalsAndAccountsByBlockHash"}, []string{"debug_*"}, "debug_traceInternalsAndAccountsByBlockHash", true},
+		{[]string{"debug_traceInternalsAndAccountsByBlockHash"}, []string{"debug_*"}, "debug_traceTransaction", false},
+		{[]string{"eth_*"}, nil, "net_version", false},
+		{[]string{"eth_*"}, []string{"eth_sendRawTransaction"}, "eth_sendRawTransaction", false},
+	}
+	for _, tt := range tests {
+		policy := NewPolicy(config.Gateway{AllowedMethods: tt.allowed, DeniedMethods: tt.denied})
+		if got := policy.Allowed(tt.method); got != tt.want {
+			t.Errorf("allowed %v denied %v: %s allowed %t, want %t", tt.allowed, tt.denied, tt.method, got, tt.want)
+		}
+	}
+}
+
+func TestPolicyClass(t *testing.T) {
+	policy := NewPolicy(config.Gateway{
+		MethodRoutes: map[string]string{"debug_*": "tracing"},
+		HistoryClass: "archive",
+		HistoryDepth: 128,
+	})
+	tests := []struct {
+		request rpc.ServerRequest
+		want    string
+	}{
+		{request(rpc.DebugTraceInternalsAndAccountsByBlockHash, `["0x01"]`), "tracing"},
+		{request(rpc.ETHGetBalance, `["0x00", "0x3e8"]`), "archive"},
+		{request(rpc.ETHGetBalance, `["0x00", "0x2710"]`), ""},
+		{request(rpc.ETHGetBalance, `["0x00", "latest"]`), ""},
+		{request(rpc.ETHCall, `[{"to": "0x00"}, "earliest"]`), "archive"},
+		{request(rpc.ETHGetBlockByNumber, `["0x1", false]`), "archive"},
+		{request(rpc.ETHGetLogs, `[{"fromBlock": "0x1", "toBlock": "latest"}]`), "archive"},
+		{request(rpc.ETHGetLogs, `[{"blockHash": "0x01"}]`), ""},
+		{request(rpc.ETHBlockNumber, `[]`), ""},
+	}
+	for _, tt := range tests {
+		if got := policy.Class(tt.request, 10000); got != tt.want {
+			t.Errorf("%s %s routed to %q, want %q", tt.request.Method, *tt.request.Params, got, tt.want)
+		}
+	}
+}
+
+func TestPolicyTimeout(t *testing.T) {
+	policy := NewPolicy(config.Gateway{Timeout: 10 * time.Second, MethodTimeouts: map[string]time.Duration{"debug_*": time.Minute, "eth_getLogs": 30 * time.Second}})
+	for method, want := range map[string]time.Duration{"debug_traceTransaction": time.Minute, rpc.ETHGetLogs: 30 * time.Second, rpc.ETHCall: 10 * time.Second} {
+		if got := policy.Timeout(request(method, `[]`)); got != want {
+			t.Errorf("%s timeout %s, want %s", method, got, want)
+		}
+	}
+}
+
+func TestPolicyRateLimits(t *testing.T) {
+	policy := NewPolicy(config.Gateway{ApiKeyRates: map[string]float64{"dapp": 2}, AnonymousRate: 1})
+	check := func(target, key string) *rpc.ErrorObject {
+		r := httptest.NewRequest("POST", target, nil)
+		if key != "" {
+			r.Header.Set(apiKeyHeader, key)
+		}
+		return policy.Check(r, request(rpc.ETHBlockNumber, `[]`))
+	}
+
+	if check("/", "dapp") != nil || check("/?apikey=dapp", "") != nil {
+		t.Fatal("requests within the key rate refused")
+	}
+	if err := check("/", "dapp"); err == nil || err.Code != rpc.LimitExceeded {
+		t.Fatalf("got %+v, want the key rate exceeded", err)
+	}
+	if check("/", "") != nil {
+		t.Fatal("anonymous request refused, the key rate is separate")
+	}
+	if err := check("/", ""); err == nil || err.Code != rpc.LimitExceeded {
+		t.Fatalf("got %+v, want the anonymous rate exceeded", err)
+	}
+	if err := check("/", "stolen"); err == nil || err.Code != rpc.InvalidRequest {
+		t.Fatalf("got %+v, want unknown keys refused", err)
+	}
+}
@@ -0,0 +1,255 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go-node-audit/pkg/rpc"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// shadow comparisons running at once, samples above it are dropped
+const maxShadowComparisons = 16
+
+// methods whose result only depends on their hash parameter
+var hashMethods = map[string]bool{
+	rpc.ETHGetBlockByHash:                    true,
+	rpc.ETHGetTransactionByHash:              true,
+	rpc.ETHGetTransactionReceipt:             true,
+	rpc.ETHGetTransactionByBlockHashAndIndex: true,
+	rpc.ETHGetBlockTransactionCountByHash:    true,
+}
+
+// Mismatch is a request a shadow node answered differently than the primary node.
+type Mismatch struct {
+	Time          time.Time       `json:"time"`
+	Method        string          `json:"method"`
+	Params        json.RawMessage `json:"params"`
+	Primary       string          `json:"primary"`
+	PrimaryResult json.RawMessage `json:"primaryResult"`
+	Shadow        string          `json:"shadow"`
+	ShadowResult  json.RawMessage `json:"shadowResult"`
+}
+
+// ShadowStats counts the comparisons with a shadow node.
+type ShadowStats struct {
+	Compared   uint64
+	Mismatched uint64
+}
+
+// Shadow sends a sample of the read-only requests the primary node answered to the shadow nodes
+// and records the results that differ. Only requests pinned to a block hash or number are
+// compared, nodes at different heights answer "latest" differently. Close aborts the
+// comparisons still running.
+type Shadow struct {
+	backends []*rpc.Backend
+	methods  map[string]bool
+	rate     float64
+	running  chan struct{}
+	ctx      context.Context
+	cancel   context.CancelFunc
+
+	mu    sync.Mutex
+	rnd   *rand.Rand
+	stats map[string]ShadowStats
+	log   *os.File
+}
+
+func NewShadow(backends []*rpc.Backend, methods []string, rate float64, logPath string) (*Shadow, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	shadow := &Shadow{
+		ctx:      ctx,
+		cancel:   cancel,
+		backends: backends,
+		methods:  make(map[string]bool, len(methods)),
+		rate:     rate,
+		running:  make(chan struct{}, maxShadowComparisons),
+		rnd:      rand.New(rand.NewSource(time.Now().UnixNano())),
+		stats:    make(map[string]ShadowStats),
+	}
+	for _, method := range methods {
+		shadow.methods[method] = true
+	}
+	if logPath != "" {
+		file, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		shadow.log = file
+	}
+	return shadow, nil
+}
+
+// Observe samples a request primary answered with response, for rpc.Server.Observe.
+func (s *Shadow) Observe(request rpc.ServerRequest, primary string, response json.RawMessage) {
+	if !s.methods[request.Method] || !pinned(request) || !s.sample() {
+		return
+	}
+	result, ok := resultOf(response)
+	if !ok {
+		return
+	}
+	select {
+	case s.running <- struct{}{}:
+	default:
+		return
+	}
+	go func() {
+		defer func() { <-s.running }()
+		s.compare(request, primary, result)
+	}()
+}
+
+func (s *Shadow) sample() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rnd.Float64() < s.rate
+}
+
+func (s *Shadow) compare(request rpc.ServerRequest, primary string, primaryResult json.RawMessage) {
+	expected, err := rpc.NormalizeJSON(primaryResult)
+	if err != nil {
+		return
+	}
+	for _, backend := range s.backends {
+		if backend.Name == primary {
+			continue
+		}
+		statusCode, body, err := backend.Client.Forward(s.ctx, request)
+		if err != nil || statusCode != http.StatusOK {
+			continue
+		}
+		result, ok := resultOf(body)
+		if !ok {
+			continue
+		}
+		got, err := rpc.NormalizeJSON(result)
+		if err != nil {
+			continue
+		}
+		matched := bytes.Equal(expected, got)
+		s.record(backend.Name, matched)
+		if matched {
+			continue
+		}
+		mismatch := Mismatch{Time: time.Now(), Method: request.Method, Primary: primary, PrimaryResult: primaryResult, Shadow: backend.Name, ShadowResult: result}
+		if request.Params != nil {
+			mismatch.Params = *request.Params
+		}
+		log.Warnf("%s node answered %s %s differently than %s node", backend.Name, request.Method, mismatch.Params, primary)
+		s.write(mismatch)
+	}
+}
+
+func (s *Shadow) record(backend string, matched bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := s.stats[backend]
+	stats.Compared++
+	if !matched {
+		stats.Mismatched++
+	}
+	s.stats[backend] = stats
+}
+
+func (s *Shadow) write(mismatch Mismatch) {
+	if s.log == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := json.NewEncoder(s.log).Encode(mismatch); err != nil {
+		log.Errorf("Cannot write shadow mismatch: %v", err)
+	}
+}
+
+func (s *Shadow) Stats() map[string]ShadowStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := make(map[string]ShadowStats, len(s.stats))
+	for backend, backendStats := range s.stats {
+		stats[backend] = backendStats
+	}
+	return stats
+}
+
+func (s *Shadow) String() string {
+	stats := s.Stats()
+	backends := make([]string, 0, len(stats))
+	for backend := range stats {
+		backends = append(backends, backend)
+	}
+	sort.Strings(backends)
+
+	var b strings.Builder
+	b.WriteString("Shadow comparisons\n")
+	for _, backend := range backends {
+		fmt.Fprintf(&b, "  %-12s compared=%d mismatched=%d\n", backend, stats[backend].Compared, stats[backend].Mismatched)
+	}
+	return b.String()
+}
+
+func (s *Shadow) Close() error {
+	s.cancel()
+	// taking every slot waits for the running comparisons and refuses new ones
+	for i := 0; i < cap(s.running); i++ {
+		s.running <- struct{}{}
+	}
+	if s.log == nil {
+		return nil
+	}
+	return s.log.Close()
+}
+
+// resultOf returns the result of a response, false for errors and null results, a node
+// that has not seen a block yet is not diverging.
+func resultOf(response json.RawMessage) (json.RawMessage, bool) {
+	var decoded rpc.ServerResponse[json.RawMessage]
+	if err := json.Unmarshal(response, &decoded); err != nil || decoded.Error != nil {
+		return nil, false
+	}
+	if len(decoded.Result) == 0 || string(decoded.Result) == "null" {
+		return nil, false
+	}
+	return decoded.Result, true
+}
+
+// pinned reports whether request reads at a block hash or number rather than a tag.
+func pinned(request rpc.ServerRequest) bool {
+	if hashMethods[request.Method] {
+		return true
+	}
+	if request.Method != rpc.ETHGetLogs {
+		_, ok := blockNumber(request)
+		return ok
+	}
+	if request.Params == nil {
+		return false
+	}
+	var filters []struct {
+		BlockHash *string `json:"blockHash"`
+		FromBlock string  `json:"fromBlock"`
+		ToBlock   string  `json:"toBlock"`
+	}
+	if err := json.Unmarshal(*request.Params, &filters); err != nil || len(filters) == 0 {
+		return false
+	}
+	filter := filters[0]
+	if filter.BlockHash != nil {
+		return true
+	}
+	_, fromErr := hexutil.DecodeUint64(filter.FromBlock)
+	_, toErr := hexutil.DecodeUint64(filter.ToBlock)
+	return fromErr == nil && toErr == nil
+}
@@ -0,0 +1,99 @@
+package gateway
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-node-audit/pkg/rpc"
+)
+
+// resultBackend answers every request with result.
+func resultBackend(t *testing.T, name, result string) *rpc.Backend {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request rpc.ServerRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		json.NewEncoder(w).Encode(rpc.ServerResponse[json.RawMessage]{Version: rpc.JSONRPCVersion, ID: request.ID, Result: json.RawMessage(result)})
+	}))
+	t.Cleanup(server.Close)
+	return &rpc.Backend{Name: name, Client: rpc.NewRPCClient(rpc.JsonRpcUrl(server.URL))}
+}
+
+func TestPinned(t *testing.T) {
+	tests := []struct {
+		request rpc.ServerRequest
+		want    bool
+	}{
+		{request(rpc.ETHGetTransactionReceipt, `["0x01"]`), true},
+		{request(rpc.ETHGetBalance, `["0x00", "0x10"]`), true},
+		{request(rpc.ETHGetBalance, `["0x00", "latest"]`), false},
+		{request(rpc.ETHCall, `[{"to": "0x00"}]`), false},
+		{request(rpc.ETHGetLogs, `[{"blockHash": "0x01"}]`), true},
+		{request(rpc.ETHGetLogs, `[{"fromBlock": "0x1", "toBlock": "0x2"}]`), true},
+		{request(rpc.ETHGetLogs, `[{"fromBlock": "0x1"}]`), false},
+	}
+	for _, tt := range tests {
+		if got := pinned(tt.request); got != tt.want {
+			t.Errorf("%s %s pinned %t, want %t", tt.request.Method, *tt.request.Params, got, tt.want)
+		}
+	}
+}
+
+func TestShadowRecordsMismatches(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mismatches.jsonl")
+	backends := []*rpc.Backend{
+		resultBackend(t, "infinity", `"0x10"`),
+		resultBackend(t, "eternity", `"0x10"`),
+		resultBackend(t, "catalyst", `"0x11"`),
+	}
+	shadow, err := NewShadow(backends, []string{rpc.ETHGetBalance}, 1, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shadow.Close()
+
+	id := json.RawMessage(`1`)
+	balance := request(rpc.ETHGetBalance, `["0x00", "0x64"]`)
+	balance.ID = &id
+	shadow.Observe(request(rpc.ETHGetBalance, `["0x00", "latest"]`), "infinity", json.RawMessage(`{"jsonrpc": "2.0", "id": 1, "result": "0x10"}`))
+	shadow.Observe(balance, "infinity", json.RawMessage(`{"jsonrpc": "2.0", "id": 1, "result": "0x10"}`))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(shadow.Stats()) < 2 || shadow.Stats()["catalyst"].Compared == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("shadow comparison did not finish, stats %+v", shadow.Stats())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// wait for the mismatch to be written
+	for len(shadow.running) > 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	stats := shadow.Stats()
+	if stats["eternity"] != (ShadowStats{Compared: 1}) || stats["catalyst"] != (ShadowStats{Compared: 1, Mismatched: 1}) {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	var mismatches []Mismatch
+	for scanner.Scan() {
+		var mismatch Mismatch
+		if err := json.Unmarshal(scanner.Bytes(), &mismatch); err != nil {
+			t.Fatal(err)
+		}
+		mismatches = append(mismatches, mismatch)
+	}
+	if len(mismatches) != 1 || mismatches[0].Shadow != "catalyst" || string(mismatches[0].ShadowResult) != `"0x11"` || string(mismatches[0].PrimaryResult) != `"0x10"` {
+		t.Fatalf("unexpected mismatches %+v", mismatches)
+	}
+}
@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBucket(t *testing.T) {
+	now := time.Now()
+	bucket := NewBucket(2, 3)
+	bucket.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if !bucket.Allow() {
+			t.Fatalf("request %d of the burst refused", i)
+		}
+	}
+	if bucket.Allow() {
+		t.Fatal("request above the burst allowed")
+	}
+	now = now.Add(500 * time.Millisecond)
+	if !bucket.Allow() || bucket.Allow() {
+		t.Fatal("want exactly one token after half a second at 2/s")
+	}
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		if !bucket.Allow() {
+			t.Fatalf("request %d refused after refill", i)
+		}
+	}
+	if bucket.Allow() {
+		t.Fatal("bucket refilled above its burst")
+	}
+}
+
+func TestBucketReserve(t *testing.T) {
+	now := time.Now()
+	bucket := NewBucket(4, 2)
+	bucket.now = func() time.Time { return now }
+
+	for i, want := range []time.Duration{0, 0, 250 * time.Millisecond, 500 * time.Millisecond} {
+		if wait := bucket.Reserve(); wait != want {
+			t.Fatalf("reservation %d waits %s, want %s", i, wait, want)
+		}
+	}
+	now = now.Add(500 * time.Millisecond)
+	if wait := bucket.Reserve(); wait != 250*time.Millisecond {
+		t.Fatalf("reservation after the owed tokens refilled waits %s, want 250ms", wait)
+	}
+}
+
+func TestBucketCancel(t *testing.T) {
+	now := time.Now()
+	bucket := NewBucket(4, 1)
+	bucket.now = func() time.Time { return now }
+
+	bucket.Reserve()
+	if wait := bucket.Reserve(); wait != 250*time.Millisecond {
+		t.Fatalf("second reservation waits %s, want 250ms", wait)
+	}
+	// the second caller gives up, the next one takes its place
+	bucket.Cancel()
+	if wait := bucket.Reserve(); wait != 250*time.Millisecond {
+		t.Fatalf("reservation after a cancelled one waits %s, want 250ms", wait)
+	}
+	now = now.Add(time.Hour)
+	bucket.Cancel()
+	if !bucket.Allow() || bucket.Allow() {
+		t.Fatal("cancel filled the bucket above its burst")
+	}
+}
@@ -0,0 +1,87 @@
+package stats
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Summary is the distribution of a set of durations.
+type Summary struct {
+	Count int
+	P50   time.Duration
+	P95   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+func (s Summary) String() string {
+	return fmt.Sprintf("count=%d p50=%s p95=%s p99=%s max=%s", s.Count, s.P50, s.P95, s.P99, s.Max)
+}
+
+// Summarize computes the percentiles of values without modifying it.
+func Summarize(values []time.Duration) Summary {
+	if len(values) == 0 {
+		return Summary{}
+	}
+	sorted := make([]time.Duration, len(values))
+	copy(sorted, values)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	return Summary{
+		Count: len(sorted),
+		P50:   Percentile(sorted, 50),
+		P95:   Percentile(sorted, 95),
+		P99:   Percentile(sorted, 99),
+		Max:   sorted[len(sorted)-1],
+	}
+}
+
+// Percentile returns the nearest-rank percentile p (0-100) of sorted values.
+func Percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+// Window keeps the latest size durations. It is not safe for concurrent use.
+type Window struct {
+	values []time.Duration
+	next   int
+	full   bool
+}
+
+func NewWindow(size int) *Window {
+	return &Window{values: make([]time.Duration, size)}
+}
+
+func (w *Window) Add(value time.Duration) {
+	if len(w.values) == 0 {
+		return
+	}
+	w.values[w.next] = value
+	w.next = (w.next + 1) % len(w.values)
+	if w.next == 0 {
+		w.full = true
+	}
+}
+
+func (w *Window) Len() int {
+	if w.full {
+		return len(w.values)
+	}
+	return w.next
+}
+
+func (w *Window) Summary() Summary {
+	return Summarize(w.values[:w.Len()])
+}
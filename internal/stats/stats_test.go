@@ -0,0 +1,62 @@
+package stats
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	sorted := make([]time.Duration, 100)
+	for i := range sorted {
+		sorted[i] = time.Duration(i+1) * time.Millisecond
+	}
+
+	tests := []struct {
+		name   string
+		sorted []time.Duration
+		p      float64
+		want   time.Duration
+	}{
+		{name: "empty", sorted: nil, p: 50, want: 0},
+		{name: "single", sorted: []time.Duration{7}, p: 99, want: 7},
+		{name: "p0", sorted: sorted, p: 0, want: 1 * time.Millisecond},
+		{name: "p50", sorted: sorted, p: 50, want: 50 * time.Millisecond},
+		{name: "p95", sorted: sorted, p: 95, want: 95 * time.Millisecond},
+		{name: "p100", sorted: sorted, p: 100, want: 100 * time.Millisecond},
+		{name: "nearest rank rounds up", sorted: []time.Duration{1, 2, 3}, p: 50, want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Percentile(tt.sorted, tt.p); got != tt.want {
+				t.Fatalf("Percentile() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	values := []time.Duration{5, 1, 4, 2, 3}
+	got := Summarize(values)
+	want := Summary{Count: 5, P50: 3, P95: 5, P99: 5, Max: 5}
+	if got != want {
+		t.Fatalf("Summarize() = %+v, want %+v", got, want)
+	}
+	if values[0] != 5 {
+		t.Fatal("Summarize() modified its input")
+	}
+	if (Summarize(nil) != Summary{}) {
+		t.Fatal("Summarize(nil) is not empty")
+	}
+}
+
+func TestWindow(t *testing.T) {
+	window := NewWindow(3)
+	for i := 1; i <= 5; i++ {
+		window.Add(time.Duration(i) * time.Second)
+	}
+	summary := window.Summary()
+	if summary.Count != 3 || summary.P50 != 4*time.Second || summary.Max != 5*time.Second {
+		t.Fatalf("window summary %s, want the latest 3 values", summary)
+	}
+}
@@ -1,8 +1,14 @@
 package ronin
 
 import (
+	"fmt"
+	"math/big"
+
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 // struct to interact with kafka
@@ -25,6 +31,47 @@ type Block struct {
 	TransactionsRoot common.Hash    `json:"transactionsRoot"`
 	Transactions     []common.Hash  `json:"transactions"`
 	ReceiptsRoot     common.Hash    `json:"receiptsRoot"`
+	// header fields of later forks, absent from older blocks. They are only hashed through
+	// Header, the RLP checksum of the record must not change when a fork adds one.
+	BaseFee          *hexutil.Big    `json:"baseFeePerGas,omitempty" rlp:"-"`
+	WithdrawalsHash  *common.Hash    `json:"withdrawalsRoot,omitempty" rlp:"-"`
+	BlobGasUsed      *hexutil.Uint64 `json:"blobGasUsed,omitempty" rlp:"-"`
+	ExcessBlobGas    *hexutil.Uint64 `json:"excessBlobGas,omitempty" rlp:"-"`
+	ParentBeaconRoot *common.Hash    `json:"parentBeaconBlockRoot,omitempty" rlp:"-"`
+}
+
+// Header is the consensus header of a block. The optional fields are only encoded
+// when set, or when a later optional field is set.
+type Header struct {
+	ParentHash       common.Hash
+	UncleHash        common.Hash
+	Coinbase         common.Address
+	Root             common.Hash
+	TxHash           common.Hash
+	ReceiptHash      common.Hash
+	Bloom            types.Bloom
+	Difficulty       *big.Int
+	Number           *big.Int
+	GasLimit         uint64
+	GasUsed          uint64
+	Time             uint64
+	Extra            []byte
+	MixDigest        common.Hash
+	Nonce            types.BlockNonce
+	BaseFee          *big.Int     `rlp:"optional"`
+	WithdrawalsHash  *common.Hash `rlp:"optional"`
+	BlobGasUsed      *uint64      `rlp:"optional"`
+	ExcessBlobGas    *uint64      `rlp:"optional"`
+	ParentBeaconRoot *common.Hash `rlp:"optional"`
+}
+
+// Hash is the Keccak hash of the RLP encoded header.
+func (h *Header) Hash() (common.Hash, error) {
+	encoded, err := rlp.EncodeToBytes(h)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("block %s: cannot encode header: %w", h.Number, err)
+	}
+	return crypto.Keccak256Hash(encoded), nil
 }
 
 func (b *Block) BlockNumber() uint64 {
@@ -38,3 +85,75 @@ func (b *Block) BlockHash() common.Hash {
 func (b *Block) BlockTimestamp() uint64 {
 	return uint64(b.Timestamp)
 }
+
+// Header rebuilds the consensus header of the block.
+// Ronin blocks carry no uncles, so the uncle hash is always the empty list hash.
+func (b *Block) Header() (*Header, error) {
+	bloom, err := hexutil.Decode(b.LogsBloom)
+	if err != nil {
+		return nil, fmt.Errorf("block %d: invalid logs bloom: %w", b.Number, err)
+	}
+	if len(bloom) != types.BloomByteLength {
+		return nil, fmt.Errorf("block %d: logs bloom has %d bytes, expected %d", b.Number, len(bloom), types.BloomByteLength)
+	}
+	extra, err := hexutil.Decode(b.ExtraData)
+	if err != nil {
+		return nil, fmt.Errorf("block %d: invalid extra data: %w", b.Number, err)
+	}
+	if b.Difficulty == nil {
+		return nil, fmt.Errorf("block %d: missing difficulty", b.Number)
+	}
+
+	header := &Header{
+		ParentHash:  b.ParentHash,
+		UncleHash:   types.EmptyUncleHash,
+		Coinbase:    b.Miner,
+		Root:        b.StateRoot,
+		TxHash:      b.TransactionsRoot,
+		ReceiptHash: b.ReceiptsRoot,
+		Bloom:       types.BytesToBloom(bloom),
+		Difficulty:  b.Difficulty.ToInt(),
+		Number:      new(big.Int).SetUint64(b.Number),
+		GasLimit:    uint64(b.GasLimit),
+		GasUsed:     uint64(b.GasUsed),
+		Time:        uint64(b.Timestamp),
+		Extra:       extra,
+		MixDigest:   b.MixHash,
+		Nonce:       types.EncodeNonce(b.Nonce),
+	}
+	if b.BaseFee != nil {
+		header.BaseFee = b.BaseFee.ToInt()
+	}
+	header.WithdrawalsHash = b.WithdrawalsHash
+	if b.BlobGasUsed != nil {
+		blobGasUsed := uint64(*b.BlobGasUsed)
+		header.BlobGasUsed = &blobGasUsed
+	}
+	if b.ExcessBlobGas != nil {
+		excessBlobGas := uint64(*b.ExcessBlobGas)
+		header.ExcessBlobGas = &excessBlobGas
+	}
+	header.ParentBeaconRoot = b.ParentBeaconRoot
+	return header, nil
+}
+
+// ComputeHash recomputes the Keccak hash of the rebuilt header.
+func (b *Block) ComputeHash() (common.Hash, error) {
+	header, err := b.Header()
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return header.Hash()
+}
+
+// VerifyHash checks the stored hash against the recomputed header hash.
+func (b *Block) VerifyHash() error {
+	hash, err := b.ComputeHash()
+	if err != nil {
+		return err
+	}
+	if hash != b.Hash {
+		return fmt.Errorf("block %d hash %s, recomputed header hash %s", b.Number, b.Hash.Hex(), hash.Hex())
+	}
+	return nil
+}
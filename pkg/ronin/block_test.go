@@ -0,0 +1,104 @@
+package ronin
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const emptyRoot = "0x56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421"
+
+// Ethereum mainnet genesis, a legacy header without any optional field.
+const genesisJSON = `{
+	"number": 0,
+	"hash": "0xd4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3",
+	"parentHash": "0x0000000000000000000000000000000000000000000000000000000000000000",
+	"nonce": 66,
+	"mixHash": "0x0000000000000000000000000000000000000000000000000000000000000000",
+	"stateRoot": "0xd7f8974fb5ac78d9ac099b9ad5018bedc2ce0a72dad1827a1709da30580f0544",
+	"coinbase": "0x0000000000000000000000000000000000000000",
+	"difficulty": "0x400000000",
+	"extraData": "0x11bbe8db4e347b4e8c937c1c8370e4b5ed33adb3db69cbdb7a38e1e50b1b82fa",
+	"gasLimit": "0x1388",
+	"gasUsed": "0x0",
+	"timestamp": "0x0",
+	"transactionsRoot": "` + emptyRoot + `",
+	"receiptsRoot": "` + emptyRoot + `"
+}`
+
+func genesisBlock(t *testing.T) *Block {
+	var block Block
+	if err := json.Unmarshal([]byte(genesisJSON), &block); err != nil {
+		t.Fatal(err)
+	}
+	block.LogsBloom = "0x" + strings.Repeat("00", types.BloomByteLength)
+	return &block
+}
+
+func TestVerifyHashLegacyHeader(t *testing.T) {
+	block := genesisBlock(t)
+	if err := block.VerifyHash(); err != nil {
+		t.Fatal(err)
+	}
+
+	block.GasUsed = 1
+	if err := block.VerifyHash(); err == nil {
+		t.Fatal("VerifyHash() accepted a modified header")
+	}
+}
+
+func TestHeaderOptionalFieldsMatchGeth(t *testing.T) {
+	block := genesisBlock(t)
+	block.BaseFee = (*hexutil.Big)(big.NewInt(20_000_000_000))
+	withdrawals := common.HexToHash(emptyRoot)
+
+	for _, withWithdrawals := range []bool{false, true} {
+		block.WithdrawalsHash = nil
+		if withWithdrawals {
+			block.WithdrawalsHash = &withdrawals
+		}
+		header, err := block.Header()
+		if err != nil {
+			t.Fatal(err)
+		}
+		geth := &types.Header{
+			ParentHash:      header.ParentHash,
+			UncleHash:       header.UncleHash,
+			Coinbase:        header.Coinbase,
+			Root:            header.Root,
+			TxHash:          header.TxHash,
+			ReceiptHash:     header.ReceiptHash,
+			Bloom:           header.Bloom,
+			Difficulty:      header.Difficulty,
+			Number:          header.Number,
+			GasLimit:        header.GasLimit,
+			GasUsed:         header.GasUsed,
+			Time:            header.Time,
+			Extra:           header.Extra,
+			MixDigest:       header.MixDigest,
+			Nonce:           header.Nonce,
+			BaseFee:         header.BaseFee,
+			WithdrawalsHash: header.WithdrawalsHash,
+		}
+		hash, err := header.Hash()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hash != geth.Hash() {
+			t.Fatalf("withdrawals=%t: hash %s, geth hash %s", withWithdrawals, hash.Hex(), geth.Hash().Hex())
+		}
+	}
+}
+
+func TestHeaderInvalidBloom(t *testing.T) {
+	block := genesisBlock(t)
+	block.LogsBloom = "0x00"
+	if _, err := block.Header(); err == nil {
+		t.Fatal("Header() accepted a short bloom")
+	}
+}
@@ -0,0 +1,204 @@
+package ronin
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Opcodes of the internal txs creating a contract.
+const (
+	OpcodeCreate  = "CREATE"
+	OpcodeCreate2 = "CREATE2"
+)
+
+var (
+	// EIP-2470 singleton factory
+	deploySelector = crypto.Keccak256([]byte("deploy(bytes,bytes32)"))[:4]
+	// Uniswap V2 factory, forked by Katana on Ronin
+	createPairSelector = crypto.Keccak256([]byte("createPair(address,address)"))[:4]
+	// Gnosis Safe proxy factory
+	createProxyWithNonceSelector = crypto.Keccak256([]byte("createProxyWithNonce(address,bytes,uint256)"))[:4]
+)
+
+// ExpectedContractAddress returns the CREATE address derived from sender and nonce.
+// It is only meaningful for contract creation transactions (To is nil).
+func (tx *Transaction) ExpectedContractAddress() common.Address {
+	return crypto.CreateAddress(tx.From, uint64(tx.Nonce))
+}
+
+// VerifyContractAddress checks the stored contract address against the CREATE derivation.
+// Calls must not carry a contract address at all.
+func (tx *Transaction) VerifyContractAddress() error {
+	if tx.To != nil {
+		if tx.ContractAddress != (common.Address{}) {
+			return fmt.Errorf("tx %s is a call to %s but has contract address %s", tx.Hash.Hex(), tx.To.Hex(), tx.ContractAddress.Hex())
+		}
+		return nil
+	}
+
+	expected := tx.ExpectedContractAddress()
+	if tx.ContractAddress != expected {
+		return fmt.Errorf("tx %s contract address %s, expected %s from sender %s nonce %d", tx.Hash.Hex(), tx.ContractAddress.Hex(), expected.Hex(), tx.From.Hex(), uint64(tx.Nonce))
+	}
+	return nil
+}
+
+// IsCreate2 reports whether the internal tx was created by the CREATE2 opcode.
+func (itx *InternalTransaction) IsCreate2() bool {
+	return itx.Opcode == OpcodeCreate2
+}
+
+// ExpectedCreate2Address returns the CREATE2 address derived from the creator, salt and init code.
+// The tracer stores the init code as Input but does not record the salt.
+func (itx *InternalTransaction) ExpectedCreate2Address(salt common.Hash) common.Address {
+	return crypto.CreateAddress2(itx.From, salt, crypto.Keccak256(itx.Input))
+}
+
+// VerifyCreate2Address checks the created address of a CREATE2 internal tx against salt.
+// Internal txs with any other opcode are ignored.
+func (itx *InternalTransaction) VerifyCreate2Address(salt common.Hash) error {
+	if !itx.IsCreate2() {
+		return nil
+	}
+
+	expected := itx.ExpectedCreate2Address(salt)
+	if itx.To != expected {
+		return fmt.Errorf("internal tx %d of %s created %s, expected %s from creator %s salt %s", itx.Index, itx.TransactionHash.Hex(), itx.To.Hex(), expected.Hex(), itx.From.Hex(), salt.Hex())
+	}
+	return nil
+}
+
+// VerifyContractAddresses runs VerifyContractAddress over txs and returns every failure.
+func VerifyContractAddresses(txs []Transaction) []error {
+	errs := make([]error, 0)
+	for i := range txs {
+		if err := txs[i].VerifyContractAddress(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// VerifyInternalTransactions checks the created address of every CREATE2 internal tx of tx.
+// The salt is recovered from the call into the factory: known factory ABIs give it directly,
+// for any other factory every 32-byte word of the call input is tried.
+// It returns the mismatches and the CREATE2 internal txs whose salt could not be recovered,
+// deployments by a factory none of the ABIs covers.
+func VerifyInternalTransactions(tx *Transaction, itxs []InternalTransaction) ([]error, []*InternalTransaction) {
+	errs := make([]error, 0)
+	unverified := make([]*InternalTransaction, 0)
+
+	calls := make([]*InternalTransaction, 0, len(itxs))
+	for i := range itxs {
+		if itxs[i].TransactionHash == tx.Hash {
+			calls = append(calls, &itxs[i])
+		}
+	}
+	sort.Slice(calls, func(i, j int) bool { return calls[i].Order < calls[j].Order })
+
+	for i, itx := range calls {
+		if !itx.IsCreate2() {
+			continue
+		}
+		input, ok := factoryInput(tx, calls[:i], itx.From)
+		if !ok {
+			unverified = append(unverified, itx)
+			continue
+		}
+
+		if salt, known := knownCreate2Salt(input, itx.Input); known {
+			if err := itx.VerifyCreate2Address(salt); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if !matchesAnyWord(itx, input) {
+			unverified = append(unverified, itx)
+		}
+	}
+	return errs, unverified
+}
+
+// factoryInput returns the input of the latest call into factory before the CREATE2,
+// falling back to the transaction itself when it calls the factory directly.
+func factoryInput(tx *Transaction, before []*InternalTransaction, factory common.Address) ([]byte, bool) {
+	for i := len(before) - 1; i >= 0; i-- {
+		call := before[i]
+		if call.To == factory && call.Opcode != OpcodeCreate && call.Opcode != OpcodeCreate2 {
+			return call.Input, true
+		}
+	}
+	if tx.To != nil && *tx.To == factory {
+		input, err := hexutil.Decode(tx.Input)
+		if err != nil {
+			return nil, false
+		}
+		return input, true
+	}
+	return nil, false
+}
+
+// knownCreate2Salt derives the salt for factories whose ABI is known.
+func knownCreate2Salt(input, initCode []byte) (common.Hash, bool) {
+	// deterministic deployment proxy: salt followed by the init code, no selector
+	if len(input) >= 32 && bytes.Equal(input[32:], initCode) {
+		return common.BytesToHash(input[:32]), true
+	}
+	if len(input) < 4 {
+		return common.Hash{}, false
+	}
+	selector, args := input[:4], input[4:]
+
+	switch {
+	case bytes.Equal(selector, deploySelector) && len(args) >= 64:
+		return common.BytesToHash(args[32:64]), true
+	case bytes.Equal(selector, createPairSelector) && len(args) >= 64:
+		tokenA, tokenB := common.BytesToAddress(args[:32]), common.BytesToAddress(args[32:64])
+		if bytes.Compare(tokenA.Bytes(), tokenB.Bytes()) > 0 {
+			tokenA, tokenB = tokenB, tokenA
+		}
+		return crypto.Keccak256Hash(tokenA.Bytes(), tokenB.Bytes()), true
+	case bytes.Equal(selector, createProxyWithNonceSelector) && len(args) >= 96:
+		initializer, ok := abiBytes(args, args[32:64])
+		if !ok {
+			return common.Hash{}, false
+		}
+		return crypto.Keccak256Hash(crypto.Keccak256(initializer), args[64:96]), true
+	}
+	return common.Hash{}, false
+}
+
+// abiBytes decodes a dynamic bytes argument whose head word is offset.
+func abiBytes(args, offset []byte) ([]byte, bool) {
+	start := new(big.Int).SetBytes(offset)
+	if !start.IsUint64() || start.Uint64()+32 > uint64(len(args)) {
+		return nil, false
+	}
+	begin := start.Uint64() + 32
+	length := new(big.Int).SetBytes(args[start.Uint64():begin])
+	if !length.IsUint64() || begin+length.Uint64() > uint64(len(args)) {
+		return nil, false
+	}
+	return args[begin : begin+length.Uint64()], true
+}
+
+// matchesAnyWord tries every 32-byte word of the call arguments as salt.
+func matchesAnyWord(itx *InternalTransaction, input []byte) bool {
+	args := input
+	if len(args)%32 == 4 {
+		args = args[4:]
+	}
+	initCodeHash := crypto.Keccak256(itx.Input)
+	for i := 0; i+32 <= len(args); i += 32 {
+		if crypto.CreateAddress2(itx.From, common.BytesToHash(args[i:i+32]), initCodeHash) == itx.To {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package ronin
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestVerifyContractAddress(t *testing.T) {
+	to := common.HexToAddress("0x4e59b44847b379578588920ca78fbf26c0b4956c")
+	tests := []struct {
+		name    string
+		tx      Transaction
+		wantErr bool
+	}{
+		{
+			// deployment of the deterministic deployment proxy on mainnet
+			name: "create",
+			tx: Transaction{
+				From:            common.HexToAddress("0x3fab184622dc19b6109349b94811493bf2a45362"),
+				Nonce:           0,
+				ContractAddress: common.HexToAddress("0x4e59b44847b379578588920ca78fbf26c0b4956c"),
+			},
+		},
+		{
+			// deployment of the EIP-2470 singleton factory on mainnet
+			name: "create eip-2470",
+			tx: Transaction{
+				From:            common.HexToAddress("0xBb6e024b9cFFACB947A71991E386681B1Cd1477D"),
+				Nonce:           0,
+				ContractAddress: common.HexToAddress("0xce0042B868300000d44A59004Da54A005ffdcf9f"),
+			},
+		},
+		{
+			name: "create with wrong nonce",
+			tx: Transaction{
+				From:            common.HexToAddress("0x3fab184622dc19b6109349b94811493bf2a45362"),
+				Nonce:           1,
+				ContractAddress: common.HexToAddress("0x4e59b44847b379578588920ca78fbf26c0b4956c"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "call",
+			tx:   Transaction{To: &to},
+		},
+		{
+			name:    "call with contract address",
+			tx:      Transaction{To: &to, ContractAddress: to},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.tx.VerifyContractAddress()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyContractAddress() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKnownCreate2SaltUniswapPair(t *testing.T) {
+	// USDC/WETH pair of the Uniswap V2 factory on mainnet, the ABI Katana forks on Ronin
+	factory := common.HexToAddress("0x5C69bEe701ef814a2B6a3EDD4B1652CB9cc5aA6f")
+	initCodeHash := common.HexToHash("0x96e8ac4277198ff8b6f785478aa9a39f403cb768dd02cbee326c3e7da348845f")
+	weth := common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2")
+	usdc := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	pair := common.HexToAddress("0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc")
+
+	input := append(append(common.CopyBytes(createPairSelector), common.LeftPadBytes(weth.Bytes(), 32)...), common.LeftPadBytes(usdc.Bytes(), 32)...)
+	salt, known := knownCreate2Salt(input, nil)
+	if !known {
+		t.Fatal("createPair salt not recognized")
+	}
+	if got := crypto.CreateAddress2(factory, salt, initCodeHash.Bytes()); got != pair {
+		t.Fatalf("pair address = %s, want %s", got.Hex(), pair.Hex())
+	}
+}
+
+func TestVerifyInternalTransactions(t *testing.T) {
+	// EIP-1014 example 0: creator 0x0, salt 0x0, init code 0x00
+	factory := common.Address{}
+	created := common.HexToAddress("0x4D1A2e2bB4F88F0250f26Ffff098B0b30B26BF38")
+	txHash := common.HexToHash("0x01")
+	salt := strings.Repeat("00", 32)
+
+	tests := []struct {
+		name           string
+		input          string
+		to             common.Address
+		wantErrs       int
+		wantUnverified int
+	}{
+		{name: "deployment proxy", input: "0x" + salt + "00", to: created},
+		{name: "deployment proxy wrong address", input: "0x" + salt + "00", to: common.HexToAddress("0x01"), wantErrs: 1},
+		{name: "unknown factory, salt in input", input: "0x12345678" + salt, to: created},
+		{name: "unknown factory, salt not in input", input: "0x12345678" + strings.Repeat("11", 32), to: created, wantUnverified: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := &Transaction{Hash: txHash, To: &factory, Input: tt.input}
+			itxs := []InternalTransaction{{
+				Opcode:          OpcodeCreate2,
+				Order:           1,
+				TransactionHash: txHash,
+				From:            factory,
+				To:              tt.to,
+				Input:           hexutil.MustDecode("0x00"),
+			}}
+			errs, unverified := VerifyInternalTransactions(tx, itxs)
+			if len(errs) != tt.wantErrs || len(unverified) != tt.wantUnverified {
+				t.Fatalf("VerifyInternalTransactions() = %v, %d, want %d errors, %d unverified", errs, len(unverified), tt.wantErrs, tt.wantUnverified)
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+package ronin
+
+// Entity names the kind of record published by ronin-subscriber.
+type Entity string
+
+const (
+	EntityBlock               Entity = "block"
+	EntityTransaction         Entity = "transaction"
+	EntityLog                 Entity = "log"
+	EntityInternalTransaction Entity = "internal_tx"
+	EntityDirtyAccount        Entity = "dirty_account"
+)
@@ -0,0 +1,121 @@
+package ronin
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// TimestampMismatch is a record whose timestamp disagrees with its block, a record whose
+// block hash is not among the checked blocks (Field "blockHash"), or a block whose
+// timestamp does not increase along the chain.
+type TimestampMismatch struct {
+	Entity         Entity
+	ID             string
+	Field          string
+	BlockHash      common.Hash
+	BlockNumber    uint64
+	BlockTimestamp uint64
+	Timestamp      uint64
+}
+
+func (m TimestampMismatch) String() string {
+	if m.Entity == EntityBlock {
+		return fmt.Sprintf("block %d (%s) timestamp %d is not after previous block timestamp %d", m.BlockNumber, m.ID, m.Timestamp, m.BlockTimestamp)
+	}
+	if m.Field == "blockHash" {
+		return fmt.Sprintf("%s %s with timestamp %d references unknown block hash %s at block %d", m.Entity, m.ID, m.Timestamp, m.BlockHash.Hex(), m.BlockNumber)
+	}
+	return fmt.Sprintf("%s %s %s %d differs from block %d timestamp %d", m.Entity, m.ID, m.Field, m.Timestamp, m.BlockNumber, m.BlockTimestamp)
+}
+
+// CheckTimestamps compares the timestamps of txs, logs and internal txs with their enclosing block
+// and checks that block timestamps strictly increase from parent to child.
+// Records whose block hash is not in blocks are reported with Field "blockHash".
+func CheckTimestamps(blocks []Block, txs []Transaction, logs []Log, itxs []InternalTransaction) []TimestampMismatch {
+	mismatches := make([]TimestampMismatch, 0)
+	byHash := make(map[common.Hash]*Block, len(blocks))
+	for i := range blocks {
+		byHash[blocks[i].Hash] = &blocks[i]
+	}
+
+	check := func(entity Entity, id string, blockHash common.Hash, blockNumber uint64, timestamps map[string]uint64) {
+		block, ok := byHash[blockHash]
+		if !ok {
+			mismatches = append(mismatches, TimestampMismatch{
+				Entity:      entity,
+				ID:          id,
+				Field:       "blockHash",
+				BlockHash:   blockHash,
+				BlockNumber: blockNumber,
+				Timestamp:   timestamps["timestamp"],
+			})
+			return
+		}
+		for _, field := range []string{"timestamp", "blockTime"} {
+			timestamp, ok := timestamps[field]
+			if !ok || timestamp == block.BlockTimestamp() {
+				continue
+			}
+			mismatches = append(mismatches, TimestampMismatch{
+				Entity:         entity,
+				ID:             id,
+				Field:          field,
+				BlockHash:      blockHash,
+				BlockNumber:    block.Number,
+				BlockTimestamp: block.BlockTimestamp(),
+				Timestamp:      timestamp,
+			})
+		}
+	}
+
+	for _, tx := range txs {
+		check(EntityTransaction, tx.Hash.Hex(), tx.BlockHash, tx.BlockNumber, map[string]uint64{"timestamp": tx.TimeStamp})
+	}
+	for _, l := range logs {
+		check(EntityLog, fmt.Sprintf("%s#%d", l.TxHash.Hex(), l.Index), l.BlockHash, l.BlockNumber, map[string]uint64{"timestamp": l.TimeStamp})
+	}
+	for _, itx := range itxs {
+		id := fmt.Sprintf("%s#%d", itx.TransactionHash.Hex(), itx.Index)
+		check(EntityInternalTransaction, id, itx.BlockHash, itx.Height, map[string]uint64{"timestamp": itx.TimeStamp, "blockTime": itx.BlockTime})
+	}
+
+	return append(mismatches, CheckBlockTimestamps(blocks)...)
+}
+
+// CheckBlockTimestamps reports every block whose timestamp is not greater than the one of its
+// parent. Blocks whose parent is not in blocks are skipped.
+func CheckBlockTimestamps(blocks []Block) []TimestampMismatch {
+	mismatches := make([]TimestampMismatch, 0)
+	byHash := make(map[common.Hash]*Block, len(blocks))
+	sorted := make([]*Block, len(blocks))
+	for i := range blocks {
+		byHash[blocks[i].Hash] = &blocks[i]
+		sorted[i] = &blocks[i]
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Number != sorted[j].Number {
+			return sorted[i].Number < sorted[j].Number
+		}
+		return bytes.Compare(sorted[i].Hash.Bytes(), sorted[j].Hash.Bytes()) < 0
+	})
+
+	for _, block := range sorted {
+		parent, ok := byHash[block.ParentHash]
+		if !ok || block.BlockTimestamp() > parent.BlockTimestamp() {
+			continue
+		}
+		mismatches = append(mismatches, TimestampMismatch{
+			Entity:         EntityBlock,
+			ID:             block.Hash.Hex(),
+			Field:          "timestamp",
+			BlockHash:      block.Hash,
+			BlockNumber:    block.Number,
+			BlockTimestamp: parent.BlockTimestamp(),
+			Timestamp:      block.BlockTimestamp(),
+		})
+	}
+	return mismatches
+}
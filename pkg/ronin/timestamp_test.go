@@ -0,0 +1,128 @@
+package ronin
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func TestCheckTimestamps(t *testing.T) {
+	hash1, hash2, unknown := common.HexToHash("0x01"), common.HexToHash("0x02"), common.HexToHash("0xff")
+	blocks := []Block{
+		{Number: 1, Hash: hash1, Timestamp: 100},
+		{Number: 2, Hash: hash2, Timestamp: 103},
+	}
+
+	tests := []struct {
+		name   string
+		txs    []Transaction
+		logs   []Log
+		itxs   []InternalTransaction
+		want   []string
+		entity Entity
+	}{
+		{
+			name: "consistent",
+			txs:  []Transaction{{BlockHash: hash1, BlockNumber: 1, TimeStamp: 100}},
+			logs: []Log{{BlockHash: hash2, BlockNumber: 2, TimeStamp: 103}},
+			itxs: []InternalTransaction{{BlockHash: hash2, Height: 2, TimeStamp: 103, BlockTime: 103}},
+		},
+		{
+			name:   "tx timestamp of neighbouring block",
+			txs:    []Transaction{{BlockHash: hash1, BlockNumber: 1, TimeStamp: 103}},
+			want:   []string{"timestamp"},
+			entity: EntityTransaction,
+		},
+		{
+			name:   "log timestamp",
+			logs:   []Log{{BlockHash: hash2, BlockNumber: 2, TimeStamp: 100}},
+			want:   []string{"timestamp"},
+			entity: EntityLog,
+		},
+		{
+			name:   "internal tx timestamp and blockTime",
+			itxs:   []InternalTransaction{{BlockHash: hash1, Height: 1, TimeStamp: 99, BlockTime: 101}},
+			want:   []string{"timestamp", "blockTime"},
+			entity: EntityInternalTransaction,
+		},
+		{
+			name:   "internal tx blockTime only",
+			itxs:   []InternalTransaction{{BlockHash: hash1, Height: 1, TimeStamp: 100, BlockTime: 0}},
+			want:   []string{"blockTime"},
+			entity: EntityInternalTransaction,
+		},
+		{
+			name:   "unknown block hash",
+			txs:    []Transaction{{BlockHash: unknown, BlockNumber: 2, TimeStamp: 103}},
+			want:   []string{"blockHash"},
+			entity: EntityTransaction,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mismatches := CheckTimestamps(blocks, tt.txs, tt.logs, tt.itxs)
+			if len(mismatches) != len(tt.want) {
+				t.Fatalf("CheckTimestamps() = %v, want fields %v", mismatches, tt.want)
+			}
+			for i, mismatch := range mismatches {
+				if mismatch.Field != tt.want[i] || mismatch.Entity != tt.entity {
+					t.Errorf("mismatch %d = %s %s, want %s %s", i, mismatch.Entity, mismatch.Field, tt.entity, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCheckBlockTimestamps(t *testing.T) {
+	tests := []struct {
+		name       string
+		timestamps []uint64
+		want       []uint64
+	}{
+		{name: "increasing", timestamps: []uint64{100, 103, 106}},
+		{name: "equal", timestamps: []uint64{100, 100, 106}, want: []uint64{2}},
+		{name: "decreasing", timestamps: []uint64{100, 103, 101}, want: []uint64{3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// reversed to check the blocks are ordered by number first
+			blocks := make([]Block, len(tt.timestamps))
+			for i, timestamp := range tt.timestamps {
+				number := uint64(i + 1)
+				blocks[len(blocks)-1-i] = Block{
+					Number:     number,
+					Hash:       common.BigToHash(new(big.Int).SetUint64(number)),
+					ParentHash: common.BigToHash(new(big.Int).SetUint64(number - 1)),
+					Timestamp:  hexutil.Uint64(timestamp),
+				}
+			}
+			mismatches := CheckBlockTimestamps(blocks)
+			if len(mismatches) != len(tt.want) {
+				t.Fatalf("CheckBlockTimestamps() = %v, want blocks %v", mismatches, tt.want)
+			}
+			for i, mismatch := range mismatches {
+				if mismatch.BlockNumber != tt.want[i] {
+					t.Errorf("mismatch %d is block %d, want %d", i, mismatch.BlockNumber, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCheckBlockTimestampsSiblings(t *testing.T) {
+	parent := Block{Number: 1, Hash: common.HexToHash("0x01"), Timestamp: 100}
+	// siblings at height 2, the child of the second one is compared with it only
+	first := Block{Number: 2, Hash: common.HexToHash("0x0a"), ParentHash: parent.Hash, Timestamp: 103}
+	second := Block{Number: 2, Hash: common.HexToHash("0x0b"), ParentHash: parent.Hash, Timestamp: 106}
+	child := Block{Number: 3, Hash: common.HexToHash("0x03"), ParentHash: second.Hash, Timestamp: 105}
+	orphan := Block{Number: 5, Hash: common.HexToHash("0x05"), ParentHash: common.HexToHash("0x04"), Timestamp: 1}
+
+	mismatches := CheckBlockTimestamps([]Block{orphan, child, second, first, parent})
+	if len(mismatches) != 1 || mismatches[0].BlockHash != child.Hash || mismatches[0].BlockTimestamp != 106 {
+		t.Fatalf("CheckBlockTimestamps() = %v, want the child of the second sibling", mismatches)
+	}
+}
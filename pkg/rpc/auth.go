@@ -0,0 +1,82 @@
+package rpc
+
+import (
+	"net/http"
+	"net/url"
+)
+
+const redacted = "REDACTED"
+
+// Auth authenticates the requests to an endpoint. Every field set is applied to each request.
+type Auth struct {
+	// basic auth
+	Username string
+	Password string
+	// sent as Authorization: Bearer
+	BearerToken string
+	Headers     map[string]string
+	// query parameter carrying ApiKey, for example apikey
+	ApiKeyParam string
+	ApiKey      string
+}
+
+func (a *Auth) apply(request *http.Request) {
+	if a.Username != "" || a.Password != "" {
+		request.SetBasicAuth(a.Username, a.Password)
+	}
+	if a.BearerToken != "" {
+		request.Header.Set("Authorization", "Bearer "+a.BearerToken)
+	}
+	for name, value := range a.Headers {
+		request.Header.Set(name, value)
+	}
+	if a.ApiKeyParam != "" {
+		query := request.URL.Query()
+		query.Set(a.ApiKeyParam, a.ApiKey)
+		request.URL.RawQuery = query.Encode()
+	}
+}
+
+// header returns the headers set by a, for the WebSocket handshake.
+func (a *Auth) header() http.Header {
+	request := &http.Request{Header: make(http.Header), URL: &url.URL{}}
+	a.apply(request)
+	return request.Header
+}
+
+// withApiKey returns rawUrl with the API key of a in its query.
+func (a *Auth) withApiKey(rawUrl string) string {
+	if a == nil || a.ApiKeyParam == "" {
+		return rawUrl
+	}
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		return rawUrl
+	}
+	query := parsed.Query()
+	query.Set(a.ApiKeyParam, a.ApiKey)
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+// RedactUrl hides the password and the values of the query parameters of rawUrl, endpoints
+// often carry their API key in them.
+func RedactUrl(rawUrl string) string {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		return redacted
+	}
+	if parsed.User != nil {
+		if _, ok := parsed.User.Password(); ok {
+			parsed.User = url.UserPassword(parsed.User.Username(), redacted)
+		}
+	}
+	if parsed.RawQuery != "" {
+		query := parsed.Query()
+		for name := range query {
+			query.Set(name, redacted)
+		}
+		parsed.RawQuery = query.Encode()
+	}
+	return parsed.String()
+}
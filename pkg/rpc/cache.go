@@ -0,0 +1,241 @@
+package rpc
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// methods whose result never changes once the block it is in is final
+var cacheableMethods = map[string]bool{
+	ETHGetBlockByHash:                    true,
+	ETHGetBlockByNumber:                  true,
+	ETHGetTransactionByHash:              true,
+	ETHGetTransactionReceipt:             true,
+	ETHGetTransactionByBlockHashAndIndex: true,
+	ETHGetBlockTransactionCountByHash:    true,
+	ETHGetLogs:                           true,
+}
+
+// CacheStats counts the lookups of cacheable requests.
+type CacheStats struct {
+	Hits    uint64
+	Misses  uint64
+	Entries int
+	Bytes   int
+}
+
+func (s CacheStats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+func (s CacheStats) String() string {
+	return fmt.Sprintf("hits=%d misses=%d ratio=%.2f entries=%d bytes=%d", s.Hits, s.Misses, s.HitRatio(), s.Entries, s.Bytes)
+}
+
+// Cache is a least recently used cache of results bounded to maxBytes. It keeps blocks,
+// transactions, receipts and logs by block hash, once their block is finalityDepth below
+// the highest block it has seen. Results of blocks near the head can still be reorged.
+type Cache struct {
+	mu       sync.Mutex
+	maxBytes int
+	depth    uint64
+	head     uint64
+	bytes    int
+	entries  *list.List
+	byKey    map[string]*list.Element
+	hits     uint64
+	misses   uint64
+}
+
+type cacheEntry struct {
+	key    string
+	result json.RawMessage
+}
+
+func NewCache(maxBytes int, finalityDepth uint64) *Cache {
+	return &Cache{maxBytes: maxBytes, depth: finalityDepth, entries: list.New(), byKey: make(map[string]*list.Element)}
+}
+
+// SetHead raises the head finality is counted from.
+func (c *Cache) SetHead(number uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if number > c.head {
+		c.head = number
+	}
+}
+
+// Get returns the cached result of request.
+func (c *Cache) Get(request ServerRequest) (json.RawMessage, bool) {
+	key, ok := cacheKey(request)
+	if !ok {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.byKey[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	c.entries.MoveToFront(element)
+	return element.Value.(*cacheEntry).result, true
+}
+
+// Store learns the head from block results and caches result when request is cacheable
+// and its block is final.
+func (c *Cache) Store(request ServerRequest, result json.RawMessage) {
+	if request.Method == ETHBlockNumber {
+		var number hexutil.Uint64
+		if json.Unmarshal(result, &number) == nil {
+			c.SetHead(uint64(number))
+		}
+		return
+	}
+	number, found := resultBlock(result)
+	if found && (request.Method == ETHGetBlockByNumber || request.Method == ETHGetBlockByHash) {
+		c.SetHead(number)
+	}
+	key, ok := cacheKey(request)
+	if !ok || !found || len(result) > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if number+c.depth > c.head {
+		return
+	}
+	if element, ok := c.byKey[key]; ok {
+		c.entries.MoveToFront(element)
+		return
+	}
+	c.byKey[key] = c.entries.PushFront(&cacheEntry{key: key, result: result})
+	c.bytes += len(result)
+	for c.bytes > c.maxBytes {
+		oldest := c.entries.Back()
+		entry := oldest.Value.(*cacheEntry)
+		c.entries.Remove(oldest)
+		delete(c.byKey, entry.key)
+		c.bytes -= len(entry.result)
+	}
+}
+
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{Hits: c.hits, Misses: c.misses, Entries: c.entries.Len(), Bytes: c.bytes}
+}
+
+// cacheKey returns the method and normalized params of request, false when its result
+// can change: blocks by tag and logs by block range.
+func cacheKey(request ServerRequest) (string, bool) {
+	if !cacheableMethods[request.Method] || request.Params == nil {
+		return "", false
+	}
+	var params []json.RawMessage
+	if err := json.Unmarshal(*request.Params, &params); err != nil || len(params) == 0 {
+		return "", false
+	}
+	switch request.Method {
+	case ETHGetBlockByNumber:
+		var number string
+		if json.Unmarshal(params[0], &number) != nil {
+			return "", false
+		}
+		if _, err := hexutil.DecodeUint64(number); err != nil {
+			return "", false
+		}
+	case ETHGetLogs:
+		var filter struct {
+			BlockHash *string `json:"blockHash"`
+		}
+		if json.Unmarshal(params[0], &filter) != nil || filter.BlockHash == nil {
+			return "", false
+		}
+	}
+	normalized, err := NormalizeJSON(*request.Params)
+	if err != nil {
+		return "", false
+	}
+	return request.Method + string(normalized), true
+}
+
+// resultBlock returns the block number of a block, transaction, receipt or the highest of logs.
+// It is not found for null and pending results and empty logs.
+func resultBlock(result json.RawMessage) (uint64, bool) {
+	type located struct {
+		Number      *hexutil.Uint64 `json:"number"`
+		BlockNumber *hexutil.Uint64 `json:"blockNumber"`
+	}
+	number := func(item located) (uint64, bool) {
+		switch {
+		case item.BlockNumber != nil:
+			return uint64(*item.BlockNumber), true
+		case item.Number != nil:
+			return uint64(*item.Number), true
+		}
+		return 0, false
+	}
+
+	trimmed := strings.TrimSpace(string(result))
+	if strings.HasPrefix(trimmed, "[") {
+		var items []located
+		if json.Unmarshal(result, &items) != nil || len(items) == 0 {
+			return 0, false
+		}
+		highest := uint64(0)
+		for _, item := range items {
+			n, ok := number(item)
+			if !ok {
+				return 0, false
+			}
+			if n > highest {
+				highest = n
+			}
+		}
+		return highest, true
+	}
+	var item located
+	if json.Unmarshal(result, &item) != nil {
+		return 0, false
+	}
+	return number(item)
+}
+
+// NormalizeJSON encodes data with sorted object keys and lowercase hex strings, so equal
+// values from different nodes encode the same.
+func NormalizeJSON(data json.RawMessage) ([]byte, error) {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(lowerHex(value))
+}
+
+func lowerHex(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		if strings.HasPrefix(v, "0x") || strings.HasPrefix(v, "0X") {
+			return strings.ToLower(v)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = lowerHex(v[i])
+		}
+	case map[string]interface{}:
+		for key := range v {
+			v[key] = lowerHex(v[key])
+		}
+	}
+	return value
+}
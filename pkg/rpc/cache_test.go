@@ -0,0 +1,129 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func rawRequest(method, params string) ServerRequest {
+	raw := json.RawMessage(params)
+	return ServerRequest{Version: JSONRPCVersion, Method: method, Params: &raw}
+}
+
+func TestCacheKey(t *testing.T) {
+	tests := []struct {
+		request ServerRequest
+		want    bool
+	}{
+		{rawRequest(ETHGetBlockByHash, `["0xAB", false]`), true},
+		{rawRequest(ETHGetBlockByNumber, `["0x10", false]`), true},
+		{rawRequest(ETHGetBlockByNumber, `["latest", false]`), false},
+		{rawRequest(ETHGetLogs, `[{"blockHash": "0xab"}]`), true},
+		{rawRequest(ETHGetLogs, `[{"fromBlock": "0x1", "toBlock": "0x2"}]`), false},
+		{rawRequest(ETHGetBalance, `["0x00", "0x10"]`), false},
+	}
+	for _, tt := range tests {
+		if _, got := cacheKey(tt.request); got != tt.want {
+			t.Errorf("%s %s cacheable %t, want %t", tt.request.Method, *tt.request.Params, got, tt.want)
+		}
+	}
+	a, _ := cacheKey(rawRequest(ETHGetBlockByHash, `["0xAB", false]`))
+	b, _ := cacheKey(rawRequest(ETHGetBlockByHash, `["0xab",false]`))
+	if a != b {
+		t.Errorf("keys %s and %s differ for the same params", a, b)
+	}
+}
+
+func TestCacheFinalityAndEviction(t *testing.T) {
+	cache := NewCache(60, 10)
+	receipt := func(hash string) ServerRequest { return rawRequest(ETHGetTransactionReceipt, `["`+hash+`"]`) }
+	result := func(number int) json.RawMessage {
+		return json.RawMessage(fmt.Sprintf(`{"blockNumber": "0x%x"}`, number))
+	}
+
+	cache.Store(receipt("0x01"), result(95))
+	if _, ok := cache.Get(receipt("0x01")); ok {
+		t.Fatal("receipt cached before the head is known")
+	}
+	cache.Store(rawRequest(ETHBlockNumber, `[]`), json.RawMessage(`"0x64"`))
+	cache.Store(receipt("0x01"), result(95))
+	if _, ok := cache.Get(receipt("0x01")); ok {
+		t.Fatal("receipt 5 blocks below the head cached")
+	}
+	cache.Store(receipt("0x01"), result(90))
+	cache.Store(receipt("0x02"), json.RawMessage(`null`))
+	if _, ok := cache.Get(receipt("0x01")); !ok {
+		t.Fatal("final receipt not cached")
+	}
+	if _, ok := cache.Get(receipt("0x02")); ok {
+		t.Fatal("null result cached")
+	}
+
+	// 23 bytes per entry, the third evicts the least recently used
+	cache.Store(receipt("0x03"), result(80))
+	cache.Get(receipt("0x01"))
+	cache.Store(receipt("0x04"), result(80))
+	if _, ok := cache.Get(receipt("0x03")); ok {
+		t.Fatal("least recently used entry not evicted")
+	}
+	if _, ok := cache.Get(receipt("0x01")); !ok {
+		t.Fatal("recently used entry evicted")
+	}
+	stats := cache.Stats()
+	if stats.Entries != 2 || stats.Bytes > 60 || stats.Hits != 3 || stats.Misses != 4 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestClientCache(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		var requests []ServerRequest
+		json.NewDecoder(r.Body).Decode(&requests)
+		responses := make([]ServerResponse[json.RawMessage], len(requests))
+		for i, request := range requests {
+			var params []string
+			json.Unmarshal(*request.Params, &params)
+			responses[i] = ServerResponse[json.RawMessage]{Version: JSONRPCVersion, ID: request.ID,
+				Result: json.RawMessage(fmt.Sprintf(`{"transactionHash": "%s", "blockNumber": "0x1"}`, params[0]))}
+		}
+		json.NewEncoder(w).Encode(responses)
+	}))
+	defer server.Close()
+	client := NewRPCClient(JsonRpcUrl(server.URL))
+	client.Cache = NewCache(1<<20, 10)
+	client.Cache.SetHead(100)
+
+	hashes := []common.Hash{common.HexToHash("0x01"), common.HexToHash("0x02")}
+	for i := 0; i < 2; i++ {
+		receipts, err := client.BatchGetTransactionReceipt(context.Background(), hashes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(receipts) != 2 || receipts[1].TransactionHash != hashes[1] {
+			t.Fatalf("unexpected receipts %+v", receipts)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("%d batches sent, want the second answered from the cache", calls)
+	}
+	if uncached := client.Uncached(); uncached.Cache != nil || client.Cache == nil {
+		t.Fatal("Uncached changed the client or kept the cache")
+	}
+}
+
+func TestNormalizeJSON(t *testing.T) {
+	a, _ := NormalizeJSON(json.RawMessage(`{"to": "0xAbC", "logs": [{"data": "0x0A"}], "status": "0x1"}`))
+	b, _ := NormalizeJSON(json.RawMessage(`{"status": "0x1", "logs": [{"data": "0x0a"}], "to": "0xabc"}`))
+	if string(a) != string(b) {
+		t.Fatalf("%s and %s differ after normalization", a, b)
+	}
+}
@@ -0,0 +1,138 @@
+package rpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// errors.Is matches an *Error against the sentinel of its code, and against the sentinels of
+// the messages nodes answer under ServerErrorInGeneral.
+var (
+	ErrParse          = &sentinel{code: ParseErrorCode, text: "parse error"}
+	ErrInvalidRequest = &sentinel{code: InvalidRequest, text: "invalid request"}
+	ErrMethodNotFound = &sentinel{code: MethodNotFound, text: "method not found"}
+	ErrInvalidParams  = &sentinel{code: InvalidParam, text: "invalid params"}
+	ErrInternal       = &sentinel{code: InternalError, text: "internal error"}
+	ErrRequestTimeout = &sentinel{code: RequestTimeout, text: "request timeout"}
+	ErrServer         = &sentinel{code: ServerErrorInGeneral, text: "server error"}
+	ErrForwardNeeded  = &sentinel{code: SmartGatewayForwardNeeded, text: "forward needed"}
+	ErrLimitExceeded  = &sentinel{code: LimitExceeded, text: "limit exceeded"}
+
+	ErrHeaderNotFound     = &sentinel{message: "header not found"}
+	ErrUnknownBlock       = &sentinel{message: "unknown block"}
+	ErrMissingTrieNode    = &sentinel{message: "missing trie node"}
+	ErrExecutionReverted  = &sentinel{message: "execution reverted"}
+	ErrBlockRangeTooLarge = &sentinel{message: "exceed maximum block range"}
+	ErrTooManyResults     = &sentinel{message: "query returned more than"}
+)
+
+// sentinel matches the errors of a code or with a message, case insensitively.
+type sentinel struct {
+	code    int
+	message string
+	text    string
+}
+
+func (s *sentinel) Error() string {
+	if s.text != "" {
+		return s.text
+	}
+	return s.message
+}
+
+// Error is an error answer of a node.
+type Error struct {
+	ErrorObject
+}
+
+func (e *Error) Error() string {
+	if e.Data != nil {
+		return fmt.Sprintf("RPC error %d: %s: %v", e.Code, e.Message, e.Data)
+	}
+	return fmt.Sprintf("RPC error %d: %s", e.Code, e.Message)
+}
+
+func (e *Error) Is(target error) bool {
+	s, ok := target.(*sentinel)
+	if !ok {
+		return false
+	}
+	if s.message != "" {
+		return strings.Contains(strings.ToLower(e.Message), s.message)
+	}
+	return e.Code == s.code
+}
+
+// TransportError is a request that got no HTTP answer: the endpoint is unreachable, the
+// connection broke or the context ended. errors.Is sees the context errors through it.
+type TransportError struct {
+	Err error
+}
+
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("cannot reach RPC server: %v", e.Err)
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// StatusError is an HTTP answer other than 200.
+type StatusError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("RPC server return status code: %d", e.StatusCode)
+}
+
+// BatchItemError is the error answer to a request of a batch.
+type BatchItemError struct {
+	// index of the request in the batch, -1 when the answer id matches no request
+	Index int
+	ID    json.RawMessage
+	Err   *Error
+}
+
+func (e *BatchItemError) Error() string {
+	return fmt.Sprintf("batch request %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchItemError) Unwrap() error {
+	return e.Err
+}
+
+// BatchError is a batch answered with errors to some of its requests. errors.Is and errors.As
+// match any of the item errors.
+type BatchError struct {
+	Items []*BatchItemError
+}
+
+func (e *BatchError) Error() string {
+	messages := make([]string, len(e.Items))
+	for i, item := range e.Items {
+		messages[i] = item.Error()
+	}
+	return fmt.Sprintf("RPC server return error response: %s", strings.Join(messages, "; "))
+}
+
+func (e *BatchError) Is(target error) bool {
+	for _, item := range e.Items {
+		if errors.Is(item, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *BatchError) As(target interface{}) bool {
+	for _, item := range e.Items {
+		if errors.As(item, target) {
+			return true
+		}
+	}
+	return false
+}
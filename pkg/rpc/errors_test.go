@@ -0,0 +1,79 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestErrorIs(t *testing.T) {
+	tests := []struct {
+		object ErrorObject
+		is     []error
+		isNot  []error
+	}{
+		{ErrorObject{Code: MethodNotFound, Message: "the method eth_foo does not exist/is not available"}, []error{ErrMethodNotFound}, []error{ErrServer, ErrHeaderNotFound}},
+		{ErrorObject{Code: ServerErrorInGeneral, Message: "header not found"}, []error{ErrServer, ErrHeaderNotFound}, []error{ErrMethodNotFound, ErrMissingTrieNode}},
+		{ErrorObject{Code: ServerErrorInGeneral, Message: "missing trie node 5e1f (path )"}, []error{ErrMissingTrieNode}, []error{ErrHeaderNotFound}},
+		{ErrorObject{Code: 3, Message: "execution reverted", Data: "0x08c379a0"}, []error{ErrExecutionReverted}, []error{ErrServer}},
+		{ErrorObject{Code: LimitExceeded, Message: "Query returned more than 10000 results"}, []error{ErrLimitExceeded, ErrTooManyResults}, []error{ErrRequestTimeout}},
+		{ErrorObject{Code: RequestTimeout, Message: "request exceeds timeout"}, []error{ErrRequestTimeout}, []error{context.DeadlineExceeded}},
+	}
+	for _, tt := range tests {
+		err := error(tt.object.ToError())
+		for _, target := range tt.is {
+			if !errors.Is(err, target) {
+				t.Errorf("%v is not %v", err, target)
+			}
+		}
+		for _, target := range tt.isNot {
+			if errors.Is(err, target) {
+				t.Errorf("%v is %v", err, target)
+			}
+		}
+		var rpcErr *Error
+		if !errors.As(err, &rpcErr) || rpcErr.Code != tt.object.Code {
+			t.Errorf("cannot get the code of %v", err)
+		}
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var requests []ServerRequest
+		if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		// the second block is pruned
+		responses := []ServerResponse[json.RawMessage]{
+			{Version: JSONRPCVersion, ID: requests[1].ID, Error: &ErrorObject{Code: ServerErrorInGeneral, Message: "header not found"}},
+			{Version: JSONRPCVersion, ID: requests[0].ID, Result: json.RawMessage(`{"number": "0x1"}`)},
+		}
+		json.NewEncoder(w).Encode(responses)
+	}))
+	defer server.Close()
+	client := NewRPCClient(JsonRpcUrl(server.URL))
+
+	_, err := client.BatchGetBlockByNumber(context.Background(), []uint64{1, 2})
+	var item *BatchItemError
+	if !errors.Is(err, ErrHeaderNotFound) || !errors.As(err, &item) || item.Index != 1 {
+		t.Fatalf("got %v, want header not found for the second request", err)
+	}
+
+	_, err = client.ChainId(context.Background())
+	var status *StatusError
+	if !errors.As(err, &status) || status.StatusCode != http.StatusBadGateway {
+		t.Fatalf("got %v, want status 502", err)
+	}
+
+	server.Close()
+	_, err = client.ChainId(context.Background())
+	var transport *TransportError
+	if !errors.As(err, &transport) {
+		t.Fatalf("got %v, want a transport error", err)
+	}
+}
@@ -0,0 +1,70 @@
+package rpc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ForwardStats counts the requests a backend asked to forward with SmartGatewayForwardNeeded.
+type ForwardStats struct {
+	Forwarded uint64
+	// forwarded requests a target answered
+	Answered uint64
+	// requests no target was left for
+	Unserved uint64
+}
+
+type forwardStats struct {
+	mu    sync.Mutex
+	stats map[string]ForwardStats
+}
+
+func newForwardStats() *forwardStats {
+	return &forwardStats{stats: make(map[string]ForwardStats)}
+}
+
+func (f *forwardStats) record(backend string, forwarded, answered bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stats := f.stats[backend]
+	switch {
+	case !forwarded:
+		stats.Unserved++
+	case answered:
+		stats.Forwarded++
+		stats.Answered++
+	default:
+		stats.Forwarded++
+	}
+	f.stats[backend] = stats
+}
+
+// ForwardStats returns the forwarding counts per backend that asked to forward.
+func (s *Server) ForwardStats() map[string]ForwardStats {
+	s.forwards.mu.Lock()
+	defer s.forwards.mu.Unlock()
+	stats := make(map[string]ForwardStats, len(s.forwards.stats))
+	for backend, backendStats := range s.forwards.stats {
+		stats[backend] = backendStats
+	}
+	return stats
+}
+
+// ForwardReport formats ForwardStats for the logs.
+func (s *Server) ForwardReport() string {
+	stats := s.ForwardStats()
+	backends := make([]string, 0, len(stats))
+	for backend := range stats {
+		backends = append(backends, backend)
+	}
+	sort.Strings(backends)
+
+	var b strings.Builder
+	b.WriteString("Forwarded requests\n")
+	for _, backend := range backends {
+		fmt.Fprintf(&b, "  %-12s forwarded=%d answered=%d unserved=%d\n", backend, stats[backend].Forwarded, stats[backend].Answered, stats[backend].Unserved)
+	}
+	return b.String()
+}
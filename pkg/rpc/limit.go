@@ -0,0 +1,154 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go-node-audit/internal/ratelimit"
+)
+
+const (
+	// pause when a 429 answer has no usable Retry-After
+	defaultRetryAfter = time.Second
+	// longest Retry-After honored, a misconfigured endpoint should not stall the audit
+	maxRetryAfter = time.Minute
+	// times a request answered 429 is sent again after the pause
+	maxThrottledRetries = 2
+)
+
+// LimiterStats counts the requests a Limiter let through.
+type LimiterStats struct {
+	Requests  uint64
+	Delayed   uint64
+	Waited    time.Duration
+	Throttled uint64
+}
+
+func (s LimiterStats) String() string {
+	return fmt.Sprintf("requests=%d delayed=%d waited=%s throttled=%d", s.Requests, s.Delayed, s.Waited, s.Throttled)
+}
+
+// Limiter paces the requests of a client to its endpoint: at most rate requests per second in
+// bursts of burst, and at most maxInFlight at once. A 429 answer pauses every request for its
+// Retry-After. A zero rate or maxInFlight leaves that side unlimited.
+type Limiter struct {
+	bucket   *ratelimit.Bucket
+	inFlight chan struct{}
+
+	mu          sync.Mutex
+	pausedUntil time.Time
+	stats       LimiterStats
+}
+
+func NewLimiter(rate float64, burst, maxInFlight int) *Limiter {
+	limiter := &Limiter{}
+	if rate > 0 {
+		limiter.bucket = ratelimit.NewBucket(rate, burst)
+	}
+	if maxInFlight > 0 {
+		limiter.inFlight = make(chan struct{}, maxInFlight)
+	}
+	return limiter
+}
+
+// acquire blocks until a request may be sent and returns the function releasing its slot.
+// It gives up when ctx is done.
+func (l *Limiter) acquire(ctx context.Context) (func(), error) {
+	start := time.Now()
+	delayed := false
+	wait := func(d time.Duration) error {
+		delayed = true
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	l.mu.Lock()
+	paused := time.Until(l.pausedUntil)
+	l.mu.Unlock()
+	if paused > 0 {
+		if err := wait(paused); err != nil {
+			return nil, err
+		}
+	}
+	if l.bucket != nil {
+		if reserved := l.bucket.Reserve(); reserved > 0 {
+			if err := wait(reserved); err != nil {
+				l.bucket.Cancel()
+				return nil, err
+			}
+		}
+	}
+	if l.inFlight != nil {
+		select {
+		case l.inFlight <- struct{}{}:
+		default:
+			delayed = true
+			select {
+			case l.inFlight <- struct{}{}:
+			case <-ctx.Done():
+				// the request is not sent, its token is not spent
+				if l.bucket != nil {
+					l.bucket.Cancel()
+				}
+				return nil, ctx.Err()
+			}
+		}
+	}
+
+	l.mu.Lock()
+	l.stats.Requests++
+	if delayed {
+		l.stats.Delayed++
+		l.stats.Waited += time.Since(start)
+	}
+	l.mu.Unlock()
+	return func() {
+		if l.inFlight != nil {
+			<-l.inFlight
+		}
+	}, nil
+}
+
+// throttle pauses the requests for the Retry-After of a 429 answer.
+func (l *Limiter) throttle(retryAfter string) {
+	pause := parseRetryAfter(retryAfter, time.Now())
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stats.Throttled++
+	if until := time.Now().Add(pause); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+func (l *Limiter) Stats() LimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stats
+}
+
+// parseRetryAfter reads a Retry-After header, in seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	pause := defaultRetryAfter
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		pause = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(value); err == nil {
+		pause = date.Sub(now)
+		if pause < 0 {
+			pause = 0
+		}
+	}
+	if pause > maxRetryAfter {
+		pause = maxRetryAfter
+	}
+	return pause
+}
@@ -0,0 +1,137 @@
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"3", 3 * time.Second},
+		{"0", 0},
+		{"", defaultRetryAfter},
+		{"soon", defaultRetryAfter},
+		{"3600", maxRetryAfter},
+		{now.Add(5 * time.Second).Format(http.TimeFormat), 5 * time.Second},
+		{now.Add(-5 * time.Second).Format(http.TimeFormat), 0},
+	}
+	for _, test := range tests {
+		if got := parseRetryAfter(test.value, now); got != test.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", test.value, got, test.want)
+		}
+	}
+}
+
+func TestLimiterThrottled(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"jsonrpc": "2.0", "id": 1, "result": "0x7e4"}`))
+	}))
+	defer server.Close()
+	client := NewRPCClient(JsonRpcUrl(server.URL))
+	client.Limiter = NewLimiter(0, 0, 1)
+
+	chainId, err := client.ChainId(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chainId != 2020 || calls != 2 {
+		t.Fatalf("chain id %d after %d calls, want 2020 after the throttled one", chainId, calls)
+	}
+	if stats := client.Limiter.Stats(); stats.Requests != 2 || stats.Throttled != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestThrottledWithoutLimits(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"jsonrpc": "2.0", "id": 1, "result": "0x7e4"}`))
+	}))
+	defer server.Close()
+	client := NewRPCClient(JsonRpcUrl(server.URL))
+
+	if chainId, err := client.ChainId(context.Background()); err != nil || chainId != 2020 {
+		t.Fatalf("chain id %d, err %v, want 2020 after the throttled call", chainId, err)
+	}
+	if stats := client.Limiter.Stats(); stats.Throttled != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestLimiterInFlight(t *testing.T) {
+	var running, highest int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		now := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			seen := atomic.LoadInt32(&highest)
+			if now <= seen || atomic.CompareAndSwapInt32(&highest, seen, now) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte(`{"jsonrpc": "2.0", "id": 1, "result": "0x1"}`))
+	}))
+	defer server.Close()
+	client := NewRPCClient(JsonRpcUrl(server.URL))
+	client.Limiter = NewLimiter(0, 0, 2)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.PeerCount(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if highest > 2 {
+		t.Fatalf("%d requests in flight at once, want at most 2", highest)
+	}
+	if stats := client.Limiter.Stats(); stats.Requests != 6 || stats.Delayed == 0 || stats.Waited == 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestLimiterCancelledWait(t *testing.T) {
+	limiter := NewLimiter(10, 1, 0)
+	if _, err := limiter.acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := limiter.acquire(ctx); err == nil {
+		t.Fatal("acquire outlived its context")
+	}
+
+	// the abandoned token is returned, the next request waits for one token only
+	start := time.Now()
+	if _, err := limiter.acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Since(start); waited > 150*time.Millisecond {
+		t.Fatalf("waited %s, want at most one token interval of 100ms", waited)
+	}
+}
@@ -7,8 +7,6 @@ import (
 	"fmt"
 	"net/http"
 	"time"
-
-	"go.uber.org/multierr"
 )
 
 // json rpc version 2.0
@@ -23,6 +21,7 @@ const (
 	RequestTimeout            = -32608
 	ServerErrorInGeneral      = -32000
 	SmartGatewayForwardNeeded = -32001
+	LimitExceeded             = -32005
 	JSONRPCVersion            = "2.0"
 
 	ETHChainId                                = "eth_chainId"
@@ -38,7 +37,15 @@ const (
 	ETHGetTransactionCount                    = "eth_getTransactionCount"
 	ETHGetTransactionReceipt                  = "eth_getTransactionReceipt"
 	ETHGetLogs                                = "eth_getLogs"
+	ETHCall                                   = "eth_call"
+	ETHGetCode                                = "eth_getCode"
+	ETHGetStorageAt                           = "eth_getStorageAt"
+	ETHGetProof                               = "eth_getProof"
 	DebugTraceInternalsAndAccountsByBlockHash = "debug_traceInternalsAndAccountsByBlockHash"
+	ETHSyncing                                = "eth_syncing"
+	NetPeerCount                              = "net_peerCount"
+	NetListening                              = "net_listening"
+	Web3ClientVersion                         = "web3_clientVersion"
 )
 
 var InternalErrorObject = ErrorObject{Code: InternalError, Message: "Internal Error"}
@@ -84,16 +91,33 @@ func (b BatchServerResponse[T]) Errors() []ErrorObject {
 	return errs
 }
 
-func (b BatchServerResponse[T]) ToError() error {
-	errs := b.Errors()
-	if len(errs) == 0 {
-		return nil
+// ToError returns the error answers as a *BatchError, nil when there are none. The items are
+// located by matching their id with requests.
+func (b BatchServerResponse[T]) ToError(requests []ServerRequest) error {
+	indexes := make(map[string]int, len(requests))
+	for i, request := range requests {
+		if request.ID != nil {
+			indexes[string(*request.ID)] = i
+		}
 	}
-	multiErrs := make([]error, len(errs))
-	for i, err := range errs {
-		multiErrs[i] = err.ToError()
+	items := make([]*BatchItemError, 0)
+	for _, response := range b {
+		if response.Error == nil {
+			continue
+		}
+		item := &BatchItemError{Index: -1, Err: response.Error.ToError()}
+		if response.ID != nil {
+			item.ID = *response.ID
+			if i, ok := indexes[string(*response.ID)]; ok {
+				item.Index = i
+			}
+		}
+		items = append(items, item)
 	}
-	return multierr.Combine(multiErrs...)
+	if len(items) == 0 {
+		return nil
+	}
+	return &BatchError{Items: items}
 }
 
 func newServerResponse(id *json.RawMessage, result interface{}, error *ErrorObject) *ServerResponse[any] {
@@ -161,15 +185,12 @@ func (err *ErrorObject) needForward() bool {
 	return err.Code == SmartGatewayForwardNeeded
 }
 
-func (err *ErrorObject) ToError() error {
-	bytes, e := json.Marshal(err)
-	if e != nil {
-		return multierr.Append(errors.New("cannot marshal ErrorObject to json"), e)
-	}
-	return errors.New(string(bytes[:]))
+func (err *ErrorObject) ToError() *Error {
+	return &Error{ErrorObject: *err}
 }
 
-type TimeoutHandlerFunc func(chan HandlerFuncResult)
+// TimeoutHandlerFunc does the work of a request, it must give up when ctx is done.
+type TimeoutHandlerFunc func(ctx context.Context, results chan HandlerFuncResult)
 type HandlerFuncResult struct {
 	returnValue interface{}
 	errorObject *ErrorObject
@@ -187,14 +208,24 @@ func HandlingTimeout(timeout time.Duration) func(next http.Handler) http.Handler
 	}
 }
 
+// withTimeoutHandle runs invoker until it sends its result or ctx is done. The invoker gets
+// ctx, so its work stops with the request instead of running on in the background.
 func withTimeoutHandle(ctx context.Context, invoker TimeoutHandlerFunc) (returnValue interface{}, errorObject *ErrorObject) {
 	resultChan := make(chan HandlerFuncResult, 1)
-	go invoker(resultChan)
+	go invoker(ctx, resultChan)
 
 	select {
 	case <-ctx.Done():
-		return nil, &ErrorObject{Code: RequestTimeout, Message: "request exceeds timeout"}
+		return nil, contextError(ctx)
 	case result := <-resultChan:
 		return result.returnValue, result.errorObject
 	}
 }
+
+// contextError is the error of a request whose context ended before it was answered.
+func contextError(ctx context.Context) *ErrorObject {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &ErrorObject{Code: RequestTimeout, Message: "request exceeds timeout"}
+	}
+	return &ErrorObject{Code: ServerErrorInGeneral, Message: "request canceled"}
+}
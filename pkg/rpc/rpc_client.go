@@ -1,64 +1,430 @@
 package rpc
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"math/big"
 	"net/http"
-	"strings"
+	neturl "net/url"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/gofiber/fiber/v2"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/google/uuid"
-	"go.uber.org/multierr"
 )
 
-const DefaultClientTimeout = 10 * time.Second
+const (
+	DefaultClientTimeout = 10 * time.Second
+	// idle connections kept to an endpoint, the gateway sends it many requests at once
+	maxIdleConnsPerHost = 32
+)
+
+var ErrNotFound = errors.New("RPC server returned null result")
 
 type JsonRpcUrl string
 
+// JsonRPCClient sends JSON-RPC requests to an endpoint. Every call takes a context, canceling
+// it aborts the HTTP request.
 type JsonRPCClient struct {
-	*fiber.Client
+	httpClient *http.Client
 	jsonRpcUrl JsonRpcUrl
+	// results of final blocks are served from it when set
+	Cache *Cache
+	// paces the requests to the endpoint, NewRPCClient sets one without limits that only
+	// honors the Retry-After of 429 answers
+	Limiter *Limiter
+	// authenticates every request to the endpoint when set
+	Auth *Auth
 }
 
 func NewRPCClient(url JsonRpcUrl) *JsonRPCClient {
-	client := fiber.AcquireClient()
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = maxIdleConnsPerHost
 	return &JsonRPCClient{
-		Client:     client,
+		httpClient: &http.Client{Transport: transport, Timeout: DefaultClientTimeout},
 		jsonRpcUrl: url,
+		Limiter:    NewLimiter(0, 0, 0),
+	}
+}
+
+// String returns the endpoint URL with its secrets redacted, for logs.
+func (client *JsonRPCClient) String() string {
+	return RedactUrl(string(client.jsonRpcUrl))
+}
+
+// Uncached returns the client without its cache, for checks that must reach the node.
+func (client *JsonRPCClient) Uncached() *JsonRPCClient {
+	uncached := *client
+	uncached.Cache = nil
+	return &uncached
+}
+
+// Forward sends request and returns the raw answer.
+func (client *JsonRPCClient) Forward(ctx context.Context, request ServerRequest) (int, []byte, error) {
+	return client.post(ctx, string(client.jsonRpcUrl), request)
+}
+
+// ForwardBatch sends requests as a single batch and returns the raw answer.
+func (client *JsonRPCClient) ForwardBatch(ctx context.Context, requests []ServerRequest) (int, []byte, error) {
+	return client.post(ctx, string(client.jsonRpcUrl), requests)
+}
+
+// post sends body as JSON to url within the limits of the limiter. A 429 answer pauses the
+// limiter for its Retry-After and the request is sent again after it. Requests that got no
+// answer fail with a *TransportError.
+func (client *JsonRPCClient) post(ctx context.Context, url string, body interface{}) (int, []byte, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return 0, nil, err
+	}
+	for attempt := 0; ; attempt++ {
+		statusCode, raw, retryAfter, err := client.postOnce(ctx, url, payload)
+		if err != nil {
+			return 0, nil, &TransportError{Err: err}
+		}
+		if statusCode != http.StatusTooManyRequests || client.Limiter == nil {
+			return statusCode, raw, err
+		}
+		client.Limiter.throttle(retryAfter)
+		if attempt == maxThrottledRetries {
+			return statusCode, raw, nil
+		}
+	}
+}
+
+// postOnce sends payload and returns the status code, body and Retry-After header of the answer.
+func (client *JsonRPCClient) postOnce(ctx context.Context, url string, payload []byte) (int, []byte, string, error) {
+	if client.Limiter != nil {
+		release, err := client.Limiter.acquire(ctx)
+		if err != nil {
+			return 0, nil, "", err
+		}
+		defer release()
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return 0, nil, "", err
 	}
+	request.Header.Set("Content-Type", "application/json")
+	if client.Auth != nil {
+		client.Auth.apply(request)
+	}
+	response, err := client.httpClient.Do(request)
+	if err != nil {
+		// the error quotes the URL, which may carry the API key
+		var urlErr *neturl.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = RedactUrl(urlErr.URL)
+		}
+		return 0, nil, "", err
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return 0, nil, "", err
+	}
+	return response.StatusCode, body, response.Header.Get("Retry-After"), nil
+}
+
+// Call sends method with the JSON encoded params and decodes the result into result, unless it is nil.
+func (client *JsonRPCClient) Call(ctx context.Context, method string, params json.RawMessage, result interface{}) error {
+	id := jsonUUID()
+	request := ServerRequest{Version: JSONRPCVersion, Method: method, Params: &params, ID: &id}
+	var response ServerResponse[json.RawMessage]
+	if err := send(ctx, client, request, &response); err != nil {
+		return err
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(response.Result, result)
 }
 
-func (client *JsonRPCClient) Forward(requestBody ServerRequest) (int, []byte, []error) {
-	statusCode, body, errs := client.Post(string(client.jsonRpcUrl)).
-		Timeout(DefaultClientTimeout).
-		JSON(requestBody).
-		Bytes()
-	return statusCode, body, errs
+// EthCall executes a read-only call of data on contract to at block, a tag or hex number.
+func (client *JsonRPCClient) EthCall(ctx context.Context, to common.Address, data []byte, block string) ([]byte, error) {
+	params := json.RawMessage(fmt.Sprintf(`[{"to": "%s", "data": "%s"}, "%s"]`, to.Hex(), hexutil.Encode(data), block))
+	var result hexutil.Bytes
+	if err := client.Call(ctx, ETHCall, params, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
-func (client *JsonRPCClient) GetLatestBlock() (*BlockResponse, error) {
+func (client *JsonRPCClient) GetLatestBlock(ctx context.Context) (*BlockResponse, error) {
 	var response ServerResponse[BlockResponse]
-	err := send(client, blockByNumberServerRequest("latest", false), &response)
+	err := send(ctx, client, blockByNumberServerRequest("latest", false), &response)
 	if err != nil {
 		return nil, err
 	}
 	return &response.Result, nil
 }
 
-func send[R any](client *JsonRPCClient, request ServerRequest, response *ServerResponse[R]) error {
-	return sendWithUrl(client, string(client.jsonRpcUrl), request, response)
+func (client *JsonRPCClient) ChainId(ctx context.Context) (uint64, error) {
+	var response ServerResponse[hexutil.Uint64]
+	if err := send(ctx, client, noParamsRequest(ETHChainId), &response); err != nil {
+		return 0, err
+	}
+	return uint64(response.Result), nil
+}
+
+// Syncing returns nil when the node is not syncing.
+func (client *JsonRPCClient) Syncing(ctx context.Context) (*SyncStatus, error) {
+	var response ServerResponse[json.RawMessage]
+	if err := send(ctx, client, noParamsRequest(ETHSyncing), &response); err != nil {
+		return nil, err
+	}
+	if string(response.Result) == "false" {
+		return nil, nil
+	}
+	status := &SyncStatus{}
+	if err := json.Unmarshal(response.Result, status); err != nil {
+		return nil, fmt.Errorf("cannot decode sync status: %w", err)
+	}
+	return status, nil
+}
+
+func (client *JsonRPCClient) PeerCount(ctx context.Context) (uint64, error) {
+	var response ServerResponse[hexutil.Uint64]
+	if err := send(ctx, client, noParamsRequest(NetPeerCount), &response); err != nil {
+		return 0, err
+	}
+	return uint64(response.Result), nil
+}
+
+func (client *JsonRPCClient) Listening(ctx context.Context) (bool, error) {
+	var response ServerResponse[bool]
+	if err := send(ctx, client, noParamsRequest(NetListening), &response); err != nil {
+		return false, err
+	}
+	return response.Result, nil
+}
+
+func (client *JsonRPCClient) ClientVersion(ctx context.Context) (string, error) {
+	var response ServerResponse[string]
+	if err := send(ctx, client, noParamsRequest(Web3ClientVersion), &response); err != nil {
+		return "", err
+	}
+	return response.Result, nil
+}
+
+func (client *JsonRPCClient) GetBlockByNumber(ctx context.Context, number uint64) (*BlockResponse, error) {
+	var response ServerResponse[*BlockResponse]
+	err := send(ctx, client, blockByNumberServerRequest(hexutil.EncodeUint64(number), false), &response)
+	if err != nil {
+		return nil, err
+	}
+	if response.Result == nil {
+		return nil, fmt.Errorf("block %d: %w", number, ErrNotFound)
+	}
+	return response.Result, nil
+}
+
+func (client *JsonRPCClient) GetBlockByHash(ctx context.Context, hash common.Hash) (*BlockResponse, error) {
+	var response ServerResponse[*BlockResponse]
+	err := send(ctx, client, blockByHashServerRequest(hash, false), &response)
+	if err != nil {
+		return nil, err
+	}
+	if response.Result == nil {
+		return nil, fmt.Errorf("block %s: %w", hash.Hex(), ErrNotFound)
+	}
+	return response.Result, nil
+}
+
+func (client *JsonRPCClient) GetTransactionByHash(ctx context.Context, hash common.Hash) (*TransactionResponse, error) {
+	var response ServerResponse[*TransactionResponse]
+	err := send(ctx, client, transactionRequest(hash), &response)
+	if err != nil {
+		return nil, err
+	}
+	if response.Result == nil {
+		return nil, fmt.Errorf("transaction %s: %w", hash.Hex(), ErrNotFound)
+	}
+	return response.Result, nil
+}
+
+func (client *JsonRPCClient) GetTransactionReceipt(ctx context.Context, hash common.Hash) (*ReceiptResponse, error) {
+	var response ServerResponse[*ReceiptResponse]
+	err := send(ctx, client, transactionReceiptRequest(hash), &response)
+	if err != nil {
+		return nil, err
+	}
+	if response.Result == nil {
+		return nil, fmt.Errorf("receipt %s: %w", hash.Hex(), ErrNotFound)
+	}
+	return response.Result, nil
+}
+
+func (client *JsonRPCClient) GetLogsByBlockHash(ctx context.Context, hash common.Hash) ([]LogResponse, error) {
+	var response ServerResponse[[]LogResponse]
+	err := send(ctx, client, logsByBlockHash(hash), &response)
+	if err != nil {
+		return nil, err
+	}
+	return response.Result, nil
 }
 
-func sendWithUrl[R any](client *JsonRPCClient, url string, request ServerRequest, response *ServerResponse[R]) error {
-	statusCode, _, errs := client.Post(url).Timeout(DefaultClientTimeout).JSON(request).Struct(&response)
-	if len(errs) > 0 {
-		return fmt.Errorf("RPC client go errors: %s", squashErrors(errs))
+func (client *JsonRPCClient) GetBalance(ctx context.Context, address common.Address, number uint64) (*big.Int, error) {
+	var response ServerResponse[*hexutil.Big]
+	err := send(ctx, client, balanceRequest(address, hexutil.EncodeUint64(number)), &response)
+	if err != nil {
+		return nil, err
+	}
+	if response.Result == nil {
+		return nil, fmt.Errorf("balance of %s: %w", address.Hex(), ErrNotFound)
+	}
+	return response.Result.ToInt(), nil
+}
+
+func (client *JsonRPCClient) GetTransactionCount(ctx context.Context, address common.Address, number uint64) (uint64, error) {
+	var response ServerResponse[hexutil.Uint64]
+	err := send(ctx, client, transactionCountRequest(address, hexutil.EncodeUint64(number)), &response)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(response.Result), nil
+}
+
+func (client *JsonRPCClient) BatchGetBlockByNumber(ctx context.Context, numbers []uint64) ([]*BlockResponse, error) {
+	requests := make([]ServerRequest, len(numbers))
+	for i, number := range numbers {
+		requests[i] = blockByNumberServerRequest(hexutil.EncodeUint64(number), false)
+	}
+	return batch[*BlockResponse](ctx, client, requests)
+}
+
+func (client *JsonRPCClient) BatchGetTransactionByHash(ctx context.Context, hashes []common.Hash) ([]*TransactionResponse, error) {
+	requests := make([]ServerRequest, len(hashes))
+	for i, hash := range hashes {
+		requests[i] = transactionRequest(hash)
+	}
+	return batch[*TransactionResponse](ctx, client, requests)
+}
+
+func (client *JsonRPCClient) BatchGetTransactionReceipt(ctx context.Context, hashes []common.Hash) ([]*ReceiptResponse, error) {
+	requests := make([]ServerRequest, len(hashes))
+	for i, hash := range hashes {
+		requests[i] = transactionReceiptRequest(hash)
+	}
+	return batch[*ReceiptResponse](ctx, client, requests)
+}
+
+func (client *JsonRPCClient) BatchGetBalance(ctx context.Context, addresses []common.Address, number uint64) ([]*hexutil.Big, error) {
+	requests := make([]ServerRequest, len(addresses))
+	for i, address := range addresses {
+		requests[i] = balanceRequest(address, hexutil.EncodeUint64(number))
+	}
+	return batch[*hexutil.Big](ctx, client, requests)
+}
+
+func (client *JsonRPCClient) BatchGetTransactionCount(ctx context.Context, addresses []common.Address, number uint64) ([]hexutil.Uint64, error) {
+	requests := make([]ServerRequest, len(addresses))
+	for i, address := range addresses {
+		requests[i] = transactionCountRequest(address, hexutil.EncodeUint64(number))
+	}
+	return batch[hexutil.Uint64](ctx, client, requests)
+}
+
+// batch sends requests as a single batch and returns the results in request order.
+// Cached results are not requested again.
+func batch[R any](ctx context.Context, client *JsonRPCClient, requests []ServerRequest) ([]R, error) {
+	if client.Cache == nil {
+		return orderedBatch[R](ctx, client, requests)
+	}
+	raws := make([]json.RawMessage, len(requests))
+	missing := make([]ServerRequest, 0, len(requests))
+	indexes := make([]int, 0, len(requests))
+	for i, request := range requests {
+		if cached, ok := client.Cache.Get(request); ok {
+			raws[i] = cached
+			continue
+		}
+		missing = append(missing, request)
+		indexes = append(indexes, i)
+	}
+	fetched, err := orderedBatch[json.RawMessage](ctx, client, missing)
+	if err != nil {
+		return nil, err
+	}
+	for j, i := range indexes {
+		raws[i] = fetched[j]
+		client.Cache.Store(missing[j], fetched[j])
+	}
+	results := make([]R, len(requests))
+	for i, raw := range raws {
+		if err := decodeResult(raw, &results[i]); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// orderedBatch sends requests as a single batch and returns the results in request order,
+// servers are free to answer a batch in any order.
+func orderedBatch[R any](ctx context.Context, client *JsonRPCClient, requests []ServerRequest) ([]R, error) {
+	if len(requests) == 0 {
+		return []R{}, nil
+	}
+	var response BatchServerResponse[R]
+	if err := sendBatch(ctx, client, requests, &response); err != nil {
+		return nil, err
+	}
+	byID := make(map[string]R, len(response))
+	for _, item := range response {
+		if item.ID != nil {
+			byID[string(*item.ID)] = item.Result
+		}
+	}
+	results := make([]R, len(requests))
+	for i, request := range requests {
+		result, ok := byID[string(*request.ID)]
+		if !ok {
+			return nil, fmt.Errorf("batch response has no result for id %s", *request.ID)
+		}
+		results[i] = result
+	}
+	return results, nil
+}
+
+// decodeResult decodes a raw result, an omitted result leaves result zero.
+func decodeResult[R any](raw json.RawMessage, result *R) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, result)
+}
+
+func send[R any](ctx context.Context, client *JsonRPCClient, request ServerRequest, response *ServerResponse[R]) error {
+	if client.Cache == nil {
+		return sendWithUrl(ctx, client, string(client.jsonRpcUrl), request, response)
+	}
+	if cached, ok := client.Cache.Get(request); ok {
+		response.Version, response.ID = JSONRPCVersion, request.ID
+		return decodeResult(cached, &response.Result)
+	}
+	var raw ServerResponse[json.RawMessage]
+	if err := sendWithUrl(ctx, client, string(client.jsonRpcUrl), request, &raw); err != nil {
+		return err
+	}
+	client.Cache.Store(request, raw.Result)
+	response.Version, response.ID = raw.Version, raw.ID
+	return decodeResult(raw.Result, &response.Result)
+}
+
+func sendWithUrl[R any](ctx context.Context, client *JsonRPCClient, url string, request ServerRequest, response *ServerResponse[R]) error {
+	statusCode, body, err := client.post(ctx, url, request)
+	if err != nil {
+		return err
 	}
 	if statusCode != http.StatusOK {
-		return fmt.Errorf("RPC server return status code: %d", statusCode)
+		return &StatusError{StatusCode: statusCode, Body: body}
+	}
+	if err := json.Unmarshal(body, response); err != nil {
+		return fmt.Errorf("cannot decode RPC response: %w", err)
 	}
 	if response.Error != nil {
 		return response.Error.ToError()
@@ -66,16 +432,19 @@ func sendWithUrl[R any](client *JsonRPCClient, url string, request ServerRequest
 	return nil
 }
 
-func sendBatch[R any](client *JsonRPCClient, request []ServerRequest, response *BatchServerResponse[R]) error {
-	statusCode, _, errs := client.Post(string(client.jsonRpcUrl)).Timeout(DefaultClientTimeout).JSON(request).Struct(&response)
-	if len(errs) > 0 {
-		return errors.New(fmt.Sprintf("RPC client go errors: %s", squashErrors(errs)))
+func sendBatch[R any](ctx context.Context, client *JsonRPCClient, request []ServerRequest, response *BatchServerResponse[R]) error {
+	statusCode, body, err := client.post(ctx, string(client.jsonRpcUrl), request)
+	if err != nil {
+		return err
 	}
 	if statusCode != http.StatusOK {
-		return errors.New(fmt.Sprintf("RPC server return status code: %d", statusCode))
+		return &StatusError{StatusCode: statusCode, Body: body}
+	}
+	if err := json.Unmarshal(body, response); err != nil {
+		return fmt.Errorf("cannot decode RPC batch response: %w", err)
 	}
-	if err := response.ToError(); err != nil {
-		return multierr.Append(errors.New("RPC server return error response"), err)
+	if err := response.ToError(request); err != nil {
+		return err
 	}
 	return nil
 }
@@ -102,6 +471,39 @@ func blockByNumberServerRequest(number string, fullTxs bool) ServerRequest {
 	}
 }
 
+func blockByHashServerRequest(hash common.Hash, fullTxs bool) ServerRequest {
+	params := json.RawMessage(fmt.Sprintf(`["%s", %t]`, hash.Hex(), fullTxs))
+	id := jsonUUID()
+	return ServerRequest{
+		Version: JSONRPCVersion,
+		Method:  ETHGetBlockByHash,
+		Params:  &params,
+		ID:      &id,
+	}
+}
+
+func balanceRequest(address common.Address, number string) ServerRequest {
+	params := json.RawMessage(fmt.Sprintf(`["%s", "%s"]`, address.Hex(), number))
+	id := jsonUUID()
+	return ServerRequest{
+		Version: JSONRPCVersion,
+		Method:  ETHGetBalance,
+		Params:  &params,
+		ID:      &id,
+	}
+}
+
+func transactionCountRequest(address common.Address, number string) ServerRequest {
+	params := json.RawMessage(fmt.Sprintf(`["%s", "%s"]`, address.Hex(), number))
+	id := jsonUUID()
+	return ServerRequest{
+		Version: JSONRPCVersion,
+		Method:  ETHGetTransactionCount,
+		Params:  &params,
+		ID:      &id,
+	}
+}
+
 func logsByBlockHash(blockHash common.Hash) ServerRequest {
 	params := json.RawMessage(fmt.Sprintf(`[{"blockHash": "%s"}]`, blockHash.Hex()))
 	id := jsonUUID()
@@ -135,13 +537,15 @@ func transactionRequest(txHash common.Hash) ServerRequest {
 	}
 }
 
-func squashErrors(errs []error) string {
-	length := len(errs)
-	errorStrings := make([]string, length)
-	for i := 0; i < len(errs); i++ {
-		errorStrings[i] = errs[i].Error()
+func noParamsRequest(method string) ServerRequest {
+	params := json.RawMessage(`[]`)
+	id := jsonUUID()
+	return ServerRequest{
+		Version: JSONRPCVersion,
+		Method:  method,
+		Params:  &params,
+		ID:      &id,
 	}
-	return strings.Join(errorStrings, ",")
 }
 
 func jsonUUID() json.RawMessage {
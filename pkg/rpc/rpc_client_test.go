@@ -0,0 +1,40 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the server notices the client leaving once the body is read
+		io.ReadAll(r.Body)
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+	client := NewRPCClient(JsonRpcUrl(server.URL))
+	client.Limiter = NewLimiter(0, 0, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := client.GetLatestBlock(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want the deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("call returned after %s, long after its deadline", elapsed)
+	}
+
+	// the slot of the aborted request is released, a canceled context gives up waiting
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.ChainId(canceled); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want canceled", err)
+	}
+}
@@ -0,0 +1,246 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	maxRequestSize      = 5 << 20
+	DefaultMaxBatchSize = 100
+	// a forwarded request is forwarded again at most once
+	maxForwardHops = 2
+	DefaultTimeout = 10 * time.Second
+)
+
+// Backend is a node the server forwards requests to.
+type Backend struct {
+	Name   string
+	Client *JsonRPCClient
+}
+
+// Balancer picks the backends of a request.
+type Balancer interface {
+	// Backends returns the backends to try request on, best first.
+	Backends(request ServerRequest) []*Backend
+	// Failed reports that backend could not answer a request.
+	Failed(backend *Backend, err error)
+	// ForwardTargets returns the backends to forward request to when backend answers
+	// SmartGatewayForwardNeeded, best first.
+	ForwardTargets(backend *Backend, request ServerRequest) []*Backend
+}
+
+// Policy decides which requests a client may send and how long they may take.
+type Policy interface {
+	// Check returns the error to answer request of the client of r with, nil when it is allowed.
+	Check(r *http.Request, request ServerRequest) *ErrorObject
+	Timeout(request ServerRequest) time.Duration
+}
+
+// Server is a JSON-RPC gateway forwarding every request to the first backend of its
+// balancer that answers.
+type Server struct {
+	balancer     Balancer
+	forwards     *forwardStats
+	MaxBatchSize int
+	// every request is allowed and gets Timeout when nil
+	Policy  Policy
+	Timeout time.Duration
+	// called with every answer of a backend, it must not block
+	Observe func(request ServerRequest, backend string, response json.RawMessage)
+	// answers final blocks without a backend when set
+	Cache *Cache
+}
+
+func NewServer(balancer Balancer) *Server {
+	return &Server{balancer: balancer, forwards: newForwardStats(), MaxBatchSize: DefaultMaxBatchSize, Timeout: DefaultTimeout}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	if err != nil {
+		writeResponse(w, cannotParse(err.Error()))
+		return
+	}
+	if trimmed := bytes.TrimLeft(body, " \t\r\n"); len(trimmed) > 0 && trimmed[0] == '[' {
+		s.serveBatch(w, r, trimmed)
+		return
+	}
+	var request ServerRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		writeResponse(w, cannotParse(err.Error()))
+		return
+	}
+	if response := validate(request); response != nil {
+		writeResponse(w, response)
+		return
+	}
+	if errorObject := s.check(r, request); errorObject != nil {
+		writeResponse(w, newServerResponse(request.ID, nil, errorObject))
+		return
+	}
+
+	if cached, ok := s.cached(request); ok {
+		writeResponse(w, cached)
+		return
+	}
+
+	ctx, cancel := s.withTimeout(r.Context(), request)
+	defer cancel()
+	result, errorObject := withTimeoutHandle(ctx, func(ctx context.Context, results chan HandlerFuncResult) {
+		body, errorObject := s.forward(ctx, request)
+		results <- HandlerFuncResult{returnValue: body, errorObject: errorObject}
+	})
+	if errorObject != nil {
+		writeResponse(w, newServerResponse(request.ID, nil, errorObject))
+		return
+	}
+	writeResponse(w, result)
+}
+
+// validate returns the error response of an invalid request.
+func validate(request ServerRequest) *ServerResponse[any] {
+	if request.Version != JSONRPCVersion {
+		return notSupportedVersion(request.ID)
+	}
+	if request.Method == "" {
+		response := invalidRequest(request.ID, "method is missing")
+		return &response
+	}
+	if request.ID == nil {
+		response := invalidRequest(request.ID, "notifications are not supported, id is missing")
+		return &response
+	}
+	return nil
+}
+
+func (s *Server) check(r *http.Request, request ServerRequest) *ErrorObject {
+	if s.Policy == nil {
+		return nil
+	}
+	return s.Policy.Check(r, request)
+}
+
+// withTimeout bounds ctx by the longest policy timeout of requests, by Timeout without a policy.
+func (s *Server) withTimeout(ctx context.Context, requests ...ServerRequest) (context.Context, context.CancelFunc) {
+	if s.Policy == nil {
+		return context.WithTimeout(ctx, s.Timeout)
+	}
+	timeout := time.Duration(0)
+	for _, request := range requests {
+		if t := s.Policy.Timeout(request); t > timeout {
+			timeout = t
+		}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// forward sends request to the backends in turn until one answers with a JSON-RPC response.
+// Error responses are answers too, only unreachable backends and HTTP errors fail over.
+func (s *Server) forward(ctx context.Context, request ServerRequest) (json.RawMessage, *ErrorObject) {
+	backends := s.balancer.Backends(request)
+	if len(backends) == 0 {
+		return nil, &ErrorObject{Code: ServerErrorInGeneral, Message: "no healthy node available"}
+	}
+	return s.tryBackends(ctx, request, backends, make(map[string]bool), 0)
+}
+
+// tryBackends forwards request to the backends not visited yet, a backend answering
+// SmartGatewayForwardNeeded hands the request over to its forward targets. It stops when ctx
+// is done, the backend is not to blame for the request ending.
+func (s *Server) tryBackends(ctx context.Context, request ServerRequest, backends []*Backend, visited map[string]bool, hops int) (json.RawMessage, *ErrorObject) {
+	for _, backend := range backends {
+		if visited[backend.Name] {
+			continue
+		}
+		visited[backend.Name] = true
+		body, response, err := forwardTo(ctx, backend, request)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, contextError(ctx)
+			}
+			log.Warnf("%s to %s failed: %v", request.Method, backend.Name, err)
+			s.balancer.Failed(backend, err)
+			continue
+		}
+		if response.Error != nil && response.Error.needForward() {
+			return s.forwardFrom(ctx, backend, request, visited, hops)
+		}
+		s.observe(request, backend.Name, body)
+		if s.Cache != nil && response.Error == nil {
+			s.Cache.Store(request, response.Result)
+		}
+		return body, nil
+	}
+	return nil, &ErrorObject{Code: ServerErrorInGeneral, Message: "every node failed to answer"}
+}
+
+// forwardFrom sends request to the forward targets of backend. Backends already tried and
+// chains longer than maxForwardHops are never forwarded to, which breaks loops.
+func (s *Server) forwardFrom(ctx context.Context, backend *Backend, request ServerRequest, visited map[string]bool, hops int) (json.RawMessage, *ErrorObject) {
+	targets := make([]*Backend, 0)
+	if hops < maxForwardHops {
+		for _, target := range s.balancer.ForwardTargets(backend, request) {
+			if !visited[target.Name] {
+				targets = append(targets, target)
+			}
+		}
+	}
+	if len(targets) == 0 {
+		s.forwards.record(backend.Name, false, false)
+		log.Warnf("%s node asked to forward %s, no node left to forward to after %d hops", backend.Name, request.Method, hops)
+		return nil, &ErrorObject{Code: SmartGatewayForwardNeeded, Message: "no node can serve the request"}
+	}
+	body, errorObject := s.tryBackends(ctx, request, targets, visited, hops+1)
+	s.forwards.record(backend.Name, true, errorObject == nil)
+	return body, errorObject
+}
+
+func (s *Server) cached(request ServerRequest) (ServerResponse[json.RawMessage], bool) {
+	if s.Cache == nil {
+		return ServerResponse[json.RawMessage]{}, false
+	}
+	result, ok := s.Cache.Get(request)
+	return ServerResponse[json.RawMessage]{Version: JSONRPCVersion, ID: request.ID, Result: result}, ok
+}
+
+func (s *Server) observe(request ServerRequest, backend string, response json.RawMessage) {
+	if s.Observe != nil {
+		s.Observe(request, backend, response)
+	}
+}
+
+func forwardTo(ctx context.Context, backend *Backend, request ServerRequest) (json.RawMessage, *ServerResponse[json.RawMessage], error) {
+	statusCode, body, err := backend.Client.Forward(ctx, request)
+	if err != nil {
+		return nil, nil, err
+	}
+	if statusCode != http.StatusOK {
+		return nil, nil, &StatusError{StatusCode: statusCode, Body: body}
+	}
+	var response ServerResponse[json.RawMessage]
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, nil, fmt.Errorf("invalid response: %w", err)
+	}
+	if response.Result == nil && response.Error == nil {
+		return nil, nil, errors.New("response has neither result nor error")
+	}
+	return body, &response, nil
+}
+
+func writeResponse(w http.ResponseWriter, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Warnf("Cannot write response: %v", err)
+	}
+}
@@ -0,0 +1,219 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// BatchResponse is the answer to a batch request, in request order. It is the server side
+// of BatchServerResponse.
+type BatchResponse []ServerResponse[json.RawMessage]
+
+// batchGroup is the requests of a batch forwarded to the same backends.
+type batchGroup struct {
+	backends []*Backend
+	indexes  []int
+	requests []ServerRequest
+}
+
+func (s *Server) serveBatch(w http.ResponseWriter, r *http.Request, body []byte) {
+	var items []json.RawMessage
+	if err := json.Unmarshal(body, &items); err != nil {
+		writeResponse(w, cannotParse(err.Error()))
+		return
+	}
+	if len(items) == 0 {
+		writeResponse(w, invalidRequest(nil, "empty batch"))
+		return
+	}
+	if s.MaxBatchSize > 0 && len(items) > s.MaxBatchSize {
+		writeResponse(w, invalidRequest(nil, fmt.Sprintf("batch of %d requests, at most %d are allowed", len(items), s.MaxBatchSize)))
+		return
+	}
+
+	responses := make(BatchResponse, len(items))
+	requests := make([]ServerRequest, len(items))
+	valid := make([]int, 0, len(items))
+	for i, item := range items {
+		if err := json.Unmarshal(item, &requests[i]); err != nil {
+			responses[i] = rawResponse(invalidRequest(nil, err.Error()))
+			continue
+		}
+		if response := validate(requests[i]); response != nil {
+			responses[i] = rawResponse(*response)
+			continue
+		}
+		if errorObject := s.check(r, requests[i]); errorObject != nil {
+			responses[i] = rawResponse(*newServerResponse(requests[i].ID, nil, errorObject))
+			continue
+		}
+		if cached, ok := s.cached(requests[i]); ok {
+			responses[i] = cached
+			continue
+		}
+		valid = append(valid, i)
+	}
+
+	if len(valid) > 0 {
+		forwarded := make([]ServerRequest, len(valid))
+		for j, i := range valid {
+			forwarded[j] = requests[i]
+		}
+		ctx, cancel := s.withTimeout(r.Context(), forwarded...)
+		defer cancel()
+		result, errorObject := withTimeoutHandle(ctx, func(ctx context.Context, results chan HandlerFuncResult) {
+			results <- HandlerFuncResult{returnValue: s.forwardBatch(ctx, requests, valid)}
+		})
+		for _, i := range valid {
+			if errorObject != nil {
+				responses[i] = rawResponse(*newServerResponse(requests[i].ID, nil, errorObject))
+				continue
+			}
+			responses[i] = result.(BatchResponse)[i]
+		}
+	}
+	writeResponse(w, responses)
+}
+
+// forwardBatch sends the valid requests grouped by their backends, every group as one batch
+// and concurrently. The responses are at the index of their request.
+func (s *Server) forwardBatch(ctx context.Context, requests []ServerRequest, valid []int) BatchResponse {
+	groups := make(map[string]*batchGroup)
+	keys := make([]string, 0)
+	for _, i := range valid {
+		backends := s.balancer.Backends(requests[i])
+		names := make([]string, len(backends))
+		for j, backend := range backends {
+			names[j] = backend.Name
+		}
+		key := strings.Join(names, ",")
+		group, ok := groups[key]
+		if !ok {
+			group = &batchGroup{backends: backends}
+			groups[key] = group
+			keys = append(keys, key)
+		}
+		group.indexes = append(group.indexes, i)
+		group.requests = append(group.requests, requests[i])
+	}
+
+	responses := make(BatchResponse, len(requests))
+	var wg sync.WaitGroup
+	for _, key := range keys {
+		wg.Add(1)
+		go func(group *batchGroup) {
+			defer wg.Done()
+			groupResponses := s.forwardGroup(ctx, group)
+			for j, i := range group.indexes {
+				responses[i] = groupResponses[j]
+			}
+		}(groups[key])
+	}
+	wg.Wait()
+	return responses
+}
+
+// forwardGroup sends the requests of group to its backends in turn until one answers the batch.
+// The ids are replaced by request indexes, clients may reuse ids within a batch.
+func (s *Server) forwardGroup(ctx context.Context, group *batchGroup) BatchResponse {
+	responses := make(BatchResponse, len(group.requests))
+	fail := func(errorObject *ErrorObject) BatchResponse {
+		for j, request := range group.requests {
+			responses[j] = rawResponse(*newServerResponse(request.ID, nil, errorObject))
+		}
+		return responses
+	}
+	if len(group.backends) == 0 {
+		return fail(&ErrorObject{Code: ServerErrorInGeneral, Message: "no healthy node available"})
+	}
+
+	indexed := make([]ServerRequest, len(group.requests))
+	for j, request := range group.requests {
+		id := json.RawMessage(strconv.Itoa(j))
+		request.ID = &id
+		indexed[j] = request
+	}
+	for _, backend := range group.backends {
+		answers, err := forwardBatchTo(ctx, backend, indexed)
+		if err != nil {
+			if ctx.Err() != nil {
+				return fail(contextError(ctx))
+			}
+			log.Warnf("Batch of %d requests to %s failed: %v", len(indexed), backend.Name, err)
+			s.balancer.Failed(backend, err)
+			continue
+		}
+		for _, answer := range answers {
+			if answer.ID == nil {
+				continue
+			}
+			j, err := strconv.Atoi(string(*answer.ID))
+			if err != nil || j < 0 || j >= len(responses) {
+				continue
+			}
+			answer.ID = group.requests[j].ID
+			if answer.Error != nil && answer.Error.needForward() {
+				answer = s.forwardItem(ctx, backend, group.requests[j])
+			} else {
+				if s.Observe != nil {
+					if body, err := json.Marshal(answer); err == nil {
+						s.Observe(group.requests[j], backend.Name, body)
+					}
+				}
+				if s.Cache != nil && answer.Error == nil {
+					s.Cache.Store(group.requests[j], answer.Result)
+				}
+			}
+			responses[j] = answer
+		}
+		for j, request := range group.requests {
+			if responses[j].ID == nil {
+				responses[j] = rawResponse(*internalError(request.ID))
+			}
+		}
+		return responses
+	}
+	return fail(&ErrorObject{Code: ServerErrorInGeneral, Message: "every node failed to answer"})
+}
+
+// forwardItem forwards a request of a batch backend asked to forward on its own.
+func (s *Server) forwardItem(ctx context.Context, backend *Backend, request ServerRequest) ServerResponse[json.RawMessage] {
+	body, errorObject := s.forwardFrom(ctx, backend, request, map[string]bool{backend.Name: true}, 0)
+	if errorObject != nil {
+		return rawResponse(*newServerResponse(request.ID, nil, errorObject))
+	}
+	var response ServerResponse[json.RawMessage]
+	if err := json.Unmarshal(body, &response); err != nil {
+		return rawResponse(*internalError(request.ID))
+	}
+	return response
+}
+
+func forwardBatchTo(ctx context.Context, backend *Backend, requests []ServerRequest) (BatchResponse, error) {
+	statusCode, body, err := backend.Client.ForwardBatch(ctx, requests)
+	if err != nil {
+		return nil, err
+	}
+	if statusCode != http.StatusOK {
+		return nil, &StatusError{StatusCode: statusCode, Body: body}
+	}
+	var responses BatchResponse
+	if err := json.Unmarshal(body, &responses); err != nil {
+		// nodes refusing a batch answer with a single error
+		var response ServerResponse[json.RawMessage]
+		if json.Unmarshal(body, &response) == nil && response.Error != nil {
+			return nil, fmt.Errorf("batch refused: %s", response.Error.Message)
+		}
+		return nil, fmt.Errorf("invalid batch response: %w", err)
+	}
+	return responses, nil
+}
+
+func rawResponse(response ServerResponse[any]) ServerResponse[json.RawMessage] {
+	return ServerResponse[json.RawMessage]{Version: response.Version, ID: response.ID, Error: response.Error}
+}
@@ -0,0 +1,282 @@
+package rpc
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echoBackend answers every request with its name and the method as result, or with status
+// when not 200. Batches are answered in reverse order.
+func echoBackend(t *testing.T, name string, status int) *Backend {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		answer := func(request ServerRequest) ServerResponse[string] {
+			return ServerResponse[string]{Version: JSONRPCVersion, ID: request.ID, Result: name + " " + request.Method}
+		}
+		var requests []ServerRequest
+		if json.Unmarshal(body, &requests) == nil {
+			responses := make([]ServerResponse[string], len(requests))
+			for i, request := range requests {
+				responses[len(requests)-1-i] = answer(request)
+			}
+			json.NewEncoder(w).Encode(responses)
+			return
+		}
+		var request ServerRequest
+		json.Unmarshal(body, &request)
+		json.NewEncoder(w).Encode(answer(request))
+	}))
+	t.Cleanup(server.Close)
+	return &Backend{Name: name, Client: NewRPCClient(JsonRpcUrl(server.URL))}
+}
+
+// forwardingBackend asks to forward every request.
+func forwardingBackend(t *testing.T, name string) *Backend {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ServerRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		json.NewEncoder(w).Encode(ServerResponse[any]{Version: JSONRPCVersion, ID: request.ID, Error: ErrorObjectForward()})
+	}))
+	t.Cleanup(server.Close)
+	return &Backend{Name: name, Client: NewRPCClient(JsonRpcUrl(server.URL))}
+}
+
+type staticBalancer struct {
+	backends []*Backend
+	failed   []string
+	// backend name to the backends it forwards to
+	forwards map[string][]*Backend
+}
+
+func (b *staticBalancer) ForwardTargets(backend *Backend, request ServerRequest) []*Backend {
+	return b.forwards[backend.Name]
+}
+
+func (b *staticBalancer) Backends(request ServerRequest) []*Backend {
+	return b.backends
+}
+
+func (b *staticBalancer) Failed(backend *Backend, err error) {
+	b.failed = append(b.failed, backend.Name)
+}
+
+func serve(server *Server, body string) ServerResponse[json.RawMessage] {
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+	var response ServerResponse[json.RawMessage]
+	json.Unmarshal(recorder.Body.Bytes(), &response)
+	return response
+}
+
+func TestServerFailsOver(t *testing.T) {
+	balancer := &staticBalancer{backends: []*Backend{
+		echoBackend(t, "infinity", http.StatusBadGateway),
+		echoBackend(t, "eternity", http.StatusOK),
+	}}
+	response := serve(NewServer(balancer), `{"jsonrpc": "2.0", "method": "eth_blockNumber", "params": [], "id": 7}`)
+	if response.Error != nil || string(response.Result) != `"eternity eth_blockNumber"` || string(*response.ID) != "7" {
+		t.Fatalf("unexpected response %+v", response)
+	}
+	if len(balancer.failed) != 1 || balancer.failed[0] != "infinity" {
+		t.Fatalf("failed backends %v, want [infinity]", balancer.failed)
+	}
+}
+
+func TestServerRejects(t *testing.T) {
+	tests := []struct {
+		name     string
+		backends []*Backend
+		body     string
+		code     int
+	}{
+		{"not json", nil, `{"jsonrpc"`, ParseErrorCode},
+		{"version", nil, `{"jsonrpc": "1.0", "method": "eth_blockNumber", "id": 1}`, InvalidRequest},
+		{"no method", nil, `{"jsonrpc": "2.0", "id": 1}`, InvalidRequest},
+		{"no healthy node", nil, `{"jsonrpc": "2.0", "method": "eth_blockNumber", "id": 1}`, ServerErrorInGeneral},
+		{"every node fails", []*Backend{echoBackend(t, "catalyst", http.StatusServiceUnavailable)}, `{"jsonrpc": "2.0", "method": "eth_blockNumber", "id": 1}`, ServerErrorInGeneral},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := serve(NewServer(&staticBalancer{backends: tt.backends}), tt.body)
+			if response.Error == nil || response.Error.Code != tt.code {
+				t.Fatalf("got error %+v, want code %d", response.Error, tt.code)
+			}
+		})
+	}
+}
+
+func TestServerBatch(t *testing.T) {
+	balancer := &staticBalancer{backends: []*Backend{
+		echoBackend(t, "infinity", http.StatusBadGateway),
+		echoBackend(t, "eternity", http.StatusOK),
+	}}
+	server := NewServer(balancer)
+	body := `[
+		{"jsonrpc": "2.0", "method": "eth_blockNumber", "id": 1},
+		{"jsonrpc": "1.0", "method": "eth_chainId", "id": 2},
+		{"jsonrpc": "2.0", "method": "eth_chainId", "id": 1},
+		3,
+		{"jsonrpc": "2.0", "method": "net_version", "id": "a"}
+	]`
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+	var responses []ServerResponse[json.RawMessage]
+	if err := json.Unmarshal(recorder.Body.Bytes(), &responses); err != nil {
+		t.Fatalf("cannot decode %s: %v", recorder.Body.String(), err)
+	}
+
+	want := []struct {
+		id     string
+		result string
+		code   int
+	}{
+		{"1", `"eternity eth_blockNumber"`, 0},
+		{"2", "", InvalidRequest},
+		{"1", `"eternity eth_chainId"`, 0},
+		{"", "", InvalidRequest},
+		{`"a"`, `"eternity net_version"`, 0},
+	}
+	if len(responses) != len(want) {
+		t.Fatalf("got %d responses, want %d", len(responses), len(want))
+	}
+	for i, w := range want {
+		response := responses[i]
+		if w.id != "" && (response.ID == nil || string(*response.ID) != w.id) {
+			t.Errorf("response %d has id %v, want %s", i, response.ID, w.id)
+		}
+		if w.code != 0 {
+			if response.Error == nil || response.Error.Code != w.code {
+				t.Errorf("response %d error %+v, want code %d", i, response.Error, w.code)
+			}
+			continue
+		}
+		if string(response.Result) != w.result {
+			t.Errorf("response %d result %s, want %s", i, response.Result, w.result)
+		}
+	}
+	if len(balancer.failed) != 1 {
+		t.Errorf("failed backends %v, want the batch failed over once", balancer.failed)
+	}
+}
+
+func TestServerBatchLimits(t *testing.T) {
+	server := NewServer(&staticBalancer{})
+	server.MaxBatchSize = 2
+	for _, body := range []string{`[]`, `[{"id": 1}, {"id": 2}, {"id": 3}]`} {
+		response := serve(server, body)
+		if response.Error == nil || response.Error.Code != InvalidRequest {
+			t.Errorf("batch %s: got error %+v, want invalid request", body, response.Error)
+		}
+	}
+}
+
+func TestServerForwards(t *testing.T) {
+	pruned := forwardingBackend(t, "infinity")
+	archive := echoBackend(t, "infinity-nv", http.StatusOK)
+	balancer := &staticBalancer{backends: []*Backend{pruned}, forwards: map[string][]*Backend{"infinity": {archive}}}
+	server := NewServer(balancer)
+
+	response := serve(server, `{"jsonrpc": "2.0", "method": "eth_getLogs", "params": [], "id": 1}`)
+	if response.Error != nil || string(response.Result) != `"infinity-nv eth_getLogs"` {
+		t.Fatalf("unexpected response %+v", response)
+	}
+	if stats := server.ForwardStats()["infinity"]; stats.Forwarded != 1 || stats.Answered != 1 {
+		t.Fatalf("unexpected forward stats %+v", stats)
+	}
+}
+
+func TestServerForwardLoop(t *testing.T) {
+	a, b, c := forwardingBackend(t, "a"), forwardingBackend(t, "b"), forwardingBackend(t, "c")
+	balancer := &staticBalancer{backends: []*Backend{a}, forwards: map[string][]*Backend{"a": {b}, "b": {a, c}, "c": {a}}}
+	server := NewServer(balancer)
+
+	response := serve(server, `{"jsonrpc": "2.0", "method": "eth_getLogs", "params": [], "id": 1}`)
+	if response.Error == nil || response.Error.Code != SmartGatewayForwardNeeded {
+		t.Fatalf("got error %+v, want the forward to end", response.Error)
+	}
+	stats := server.ForwardStats()
+	if stats["a"].Forwarded != 1 || stats["b"].Forwarded != 1 || stats["c"].Unserved != 1 {
+		t.Fatalf("unexpected forward stats %+v", stats)
+	}
+}
+
+// methodPolicy refuses method and gives every request timeout.
+type methodPolicy struct {
+	method  string
+	timeout time.Duration
+}
+
+func (p methodPolicy) Check(r *http.Request, request ServerRequest) *ErrorObject {
+	if request.Method == p.method {
+		return &ErrorObject{Code: MethodNotFound, Message: "not available"}
+	}
+	return nil
+}
+
+func (p methodPolicy) Timeout(request ServerRequest) time.Duration {
+	return p.timeout
+}
+
+func TestServerPolicy(t *testing.T) {
+	server := NewServer(&staticBalancer{backends: []*Backend{echoBackend(t, "eternity", http.StatusOK)}})
+	server.Policy = methodPolicy{method: "admin_peers", timeout: time.Second}
+
+	response := serve(server, `{"jsonrpc": "2.0", "method": "admin_peers", "id": 1}`)
+	if response.Error == nil || response.Error.Code != MethodNotFound {
+		t.Fatalf("got error %+v, want the method refused", response.Error)
+	}
+	if response := serve(server, `{"jsonrpc": "2.0", "method": "eth_blockNumber", "id": 1}`); response.Error != nil {
+		t.Fatalf("allowed method refused: %+v", response.Error)
+	}
+
+	server.Policy = methodPolicy{timeout: time.Nanosecond}
+	response = serve(server, `{"jsonrpc": "2.0", "method": "eth_blockNumber", "id": 1}`)
+	if response.Error == nil || response.Error.Code != RequestTimeout {
+		t.Fatalf("got error %+v, want the request timed out", response.Error)
+	}
+}
+
+func TestServerTimeoutWithoutPolicy(t *testing.T) {
+	server := NewServer(&staticBalancer{backends: []*Backend{echoBackend(t, "eternity", http.StatusOK)}})
+	server.Timeout = time.Nanosecond
+
+	response := serve(server, `{"jsonrpc": "2.0", "method": "eth_blockNumber", "id": 1}`)
+	if response.Error == nil || response.Error.Code != RequestTimeout {
+		t.Fatalf("got error %+v, want the request timed out", response.Error)
+	}
+}
+
+func TestServerTimeoutAbortsForward(t *testing.T) {
+	aborted := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		<-r.Context().Done()
+		close(aborted)
+	}))
+	defer slow.Close()
+	balancer := &staticBalancer{backends: []*Backend{{Name: "catalyst", Client: NewRPCClient(JsonRpcUrl(slow.URL))}}}
+	server := NewServer(balancer)
+	server.Policy = methodPolicy{timeout: 50 * time.Millisecond}
+
+	response := serve(server, `{"jsonrpc": "2.0", "method": "eth_getLogs", "params": [], "id": 1}`)
+	if response.Error == nil || response.Error.Code != RequestTimeout {
+		t.Fatalf("got error %+v, want the request timed out", response.Error)
+	}
+	select {
+	case <-aborted:
+	case <-time.After(time.Second):
+		t.Fatal("the request to the backend was not aborted")
+	}
+	if len(balancer.failed) != 0 {
+		t.Fatalf("backends %v blamed for the timeout", balancer.failed)
+	}
+}
@@ -0,0 +1,170 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	golog "github.com/ipfs/go-log"
+)
+
+const (
+	ETHSubscribe    = "eth_subscribe"
+	ETHSubscription = "eth_subscription"
+	NewHeads        = "newHeads"
+
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 30 * time.Second
+)
+
+var log = golog.Logger("RPC")
+
+// Head is a new chain head reported by a node, with the local time it arrived.
+type Head struct {
+	Block    *BlockResponse
+	Received time.Time
+}
+
+// WsClient subscribes to new heads over a WebSocket JSON-RPC endpoint.
+type WsClient struct {
+	url string
+	// StaleTimeout closes the connection when no head arrives for that long,
+	// a silently dead connection is then reconnected like a dropped one.
+	StaleTimeout time.Duration
+	// authenticates the handshake when set
+	Auth *Auth
+}
+
+func NewWsClient(url string) *WsClient {
+	return &WsClient{url: url, StaleTimeout: time.Minute}
+}
+
+type subscriptionNotification struct {
+	Method string `json:"method"`
+	Params struct {
+		Subscription string          `json:"subscription"`
+		Result       json.RawMessage `json:"result"`
+	} `json:"params"`
+}
+
+// SubscribeNewHeads sends every new head to heads until ctx is cancelled.
+// A dropped connection is dialed again with exponential backoff and subscribed again.
+func (c *WsClient) SubscribeNewHeads(ctx context.Context, heads chan<- Head) {
+	delay := minReconnectDelay
+	for ctx.Err() == nil {
+		received, err := c.subscribe(ctx, heads)
+		if ctx.Err() != nil {
+			return
+		}
+		if received {
+			delay = minReconnectDelay
+		}
+		log.Warnf("newHeads subscription to %s dropped, reconnecting in %s: %v", RedactUrl(c.url), delay, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+// subscribe runs one connection and reports whether any head was received on it.
+func (c *WsClient) subscribe(ctx context.Context, heads chan<- Head) (bool, error) {
+	dialer := websocket.Dialer{HandshakeTimeout: DefaultClientTimeout}
+	var header http.Header
+	if c.Auth != nil {
+		header = c.Auth.header()
+	}
+	conn, _, err := dialer.DialContext(ctx, c.Auth.withApiKey(c.url), header)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	// unblock ReadJSON on cancel
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	params := json.RawMessage(fmt.Sprintf(`["%s"]`, NewHeads))
+	id := jsonUUID()
+	request := ServerRequest{Version: JSONRPCVersion, Method: ETHSubscribe, Params: &params, ID: &id}
+	conn.SetWriteDeadline(time.Now().Add(DefaultClientTimeout))
+	if err := conn.WriteJSON(request); err != nil {
+		return false, err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(DefaultClientTimeout))
+	var response ServerResponse[string]
+	if err := conn.ReadJSON(&response); err != nil {
+		return false, err
+	}
+	if response.Error != nil {
+		return false, response.Error.ToError()
+	}
+	subscription := response.Result
+	log.Infof("Subscribed to newHeads on %s", RedactUrl(c.url))
+
+	received := false
+	for {
+		conn.SetReadDeadline(time.Now().Add(c.StaleTimeout))
+		var notification subscriptionNotification
+		if err := conn.ReadJSON(&notification); err != nil {
+			return received, err
+		}
+		if notification.Method != ETHSubscription || notification.Params.Subscription != subscription {
+			continue
+		}
+		block := &BlockResponse{}
+		if err := json.Unmarshal(notification.Params.Result, block); err != nil {
+			return received, fmt.Errorf("cannot decode head: %w", err)
+		}
+		received = true
+
+		select {
+		case heads <- Head{Block: block, Received: time.Now()}:
+		case <-ctx.Done():
+			return received, ctx.Err()
+		}
+	}
+}
+
+// PollNewHeads is the fallback for nodes without a WebSocket endpoint. It fetches the
+// latest block every interval and sends it when the head hash changes.
+func (client *JsonRPCClient) PollNewHeads(ctx context.Context, interval time.Duration, heads chan<- Head) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var last BlockResponse
+	for {
+		block, err := client.GetLatestBlock(ctx)
+		if err != nil {
+			log.Debugf("Cannot poll latest block from %s: %v", client, err)
+		} else if block.Hash != last.Hash {
+			last = *block
+			select {
+			case heads <- Head{Block: block, Received: time.Now()}:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// headServer accepts a subscription per connection, sends one head and drops the connection.
+func headServer(t *testing.T, subscriptions *int32) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var request ServerRequest
+		if err := conn.ReadJSON(&request); err != nil || request.Method != ETHSubscribe {
+			return
+		}
+		n := atomic.AddInt32(subscriptions, 1)
+		subscription := fmt.Sprintf("0x%x", n)
+		conn.WriteJSON(ServerResponse[string]{Version: JSONRPCVersion, ID: request.ID, Result: subscription})
+		conn.WriteJSON(map[string]interface{}{
+			"jsonrpc": JSONRPCVersion,
+			"method":  ETHSubscription,
+			"params": map[string]interface{}{
+				"subscription": subscription,
+				"result":       json.RawMessage(fmt.Sprintf(`{"number":"0x%x"}`, n)),
+			},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSubscribeNewHeadsResubscribes(t *testing.T) {
+	var subscriptions int32
+	server := headServer(t, &subscriptions)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	heads := make(chan Head)
+	go NewWsClient("ws"+strings.TrimPrefix(server.URL, "http")).SubscribeNewHeads(ctx, heads)
+
+	for want := uint64(1); want <= 2; want++ {
+		select {
+		case head := <-heads:
+			if got := head.Block.BlockNumber(); got != want {
+				t.Fatalf("head %d, want %d", got, want)
+			}
+		case <-ctx.Done():
+			t.Fatalf("no head %d after %d subscriptions", want, atomic.LoadInt32(&subscriptions))
+		}
+	}
+}